@@ -13,8 +13,8 @@ const Home = ({ updateMovieReview }) => {
       setMessage("");
       try {
         const response = await axiosClient.get("/movies");
-        setMovies(response.data);
-        if (response.data.length === 0) {
+        setMovies(response.data.movies);
+        if (response.data.movies.length === 0) {
           setMessage("No movies found");
         }
       } catch (error) {
@@ -14,7 +14,7 @@ const Recommended = () => {
       setMessage("");
       try {
         const response = await axiosPrivate.get("/recommendedmovies");
-        setMovies(response.data);
+        setMovies(response.data.movies);
       } catch (error) {
         console.error("Error fetching recommended movies:", error);
         setMessage(error.response.data.message);
@@ -0,0 +1,62 @@
+// Package cache 提供进程内的简单缓存
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache 带过期时间的并发安全内存缓存
+// 条目数达到上限时先清理过期条目，仍然已满则清空缓存，避免内存无限增长
+type TTLCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]entry[V]
+}
+
+// New 创建缓存，ttl 为条目有效期，maxEntries 为最大条目数
+func New[V any](ttl time.Duration, maxEntries int) *TTLCache[V] {
+	return &TTLCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]entry[V]),
+	}
+}
+
+// Get 获取未过期的缓存值
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set 写入缓存值
+func (c *TTLCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]entry[V])
+		}
+	}
+	c.entries[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
@@ -0,0 +1,760 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// requireAdmin 检查当前请求者是否为管理员
+// 不是管理员时写入错误响应并返回 false，调用方应直接返回
+func requireAdmin(c *gin.Context) bool {
+	role, err := utils.GetRoleFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found in context"})
+		return false
+	}
+	if !models.IsAdminRole(role) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return false
+	}
+	return true
+}
+
+// GetMaintenanceStatus 获取当前维护模式的处理器函数（仅管理员）
+func GetMaintenanceStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mode": middleware.GetMaintenanceMode()})
+	}
+}
+
+// SetMaintenanceStatus 切换维护模式的处理器函数（仅管理员）
+// 请求体: {"mode": "off" | "read_only" | "full"}
+func SetMaintenanceStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		mode, err := middleware.ParseMaintenanceMode(req.Mode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		middleware.SetMaintenanceMode(mode)
+
+		c.JSON(http.StatusOK, gin.H{"mode": mode})
+	}
+}
+
+// purgeSummary 清除用户数据后返回的删除统计
+type purgeSummary struct {
+	UserID          string `json:"user_id"`
+	UsersDeleted    int64  `json:"users_deleted"`
+	ReviewsDeleted  int64  `json:"reviews_deleted"`
+	ProgressDeleted int64  `json:"progress_deleted"`
+	AuditDeleted    int64  `json:"audit_deleted"`
+	Transactional   bool   `json:"transactional"`
+}
+
+// AdminPurgeUser 管理员彻底删除某个用户及其关联数据的处理器函数
+// 用于处理滥用账号或代用户提出的删除请求，目标用户由路径参数 id 指定
+// 删除用户文档并级联删除其评论（同时回退电影的聚合评分）、播放进度和以其为操作者的审计日志，部署支持时在事务中执行
+// 用户文档删除后令牌版本检查找不到该用户，已签发的访问令牌和刷新令牌立即失效
+func AdminPurgeUser(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		userId := c.Param("id")
+		if userId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id required"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		summary, err := purgeUser(ctx, client, userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error purging user data"})
+			return
+		}
+		if summary.UsersDeleted == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// SetUserRole 管理员修改用户角色的处理器函数
+// 请求体: {"role": "ADMIN" | "USER"}，大小写不敏感，存储时统一为大写；不在允许集合内的角色返回 400。
+// 角色写在令牌声明中，修改后递增 token_version，使该用户此前签发的令牌立即失效，需要重新登录
+func SetUserRole(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		userId := c.Param("id")
+		if userId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id required"})
+			return
+		}
+
+		var req models.RoleChange
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		if !models.IsValidRole(req.Role) {
+			respondFieldError(c, "role", fmt.Errorf("must be one of %s, %s", models.RoleAdmin, models.RoleUser))
+			return
+		}
+		role := models.NormalizeRole(req.Role)
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{
+			"$set": bson.M{"role": role, "updated_at": time.Now()},
+			"$inc": bson.M{"token_version": 1},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user role"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "role": role})
+	}
+}
+
+// purgeUser 删除用户及其关联数据
+// 优先在事务中执行；单节点 MongoDB 不支持事务时退化为顺序执行
+func purgeUser(ctx context.Context, client *mongo.Client, userId string) (purgeSummary, error) {
+	session, err := client.StartSession()
+	if err != nil {
+		return purgeSummary{}, err
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(txCtx context.Context) (any, error) {
+		return purgeUserData(txCtx, client, userId)
+	})
+	if err == nil {
+		summary := result.(purgeSummary)
+		summary.Transactional = true
+		return summary, nil
+	}
+
+	// 错误码 20 (IllegalOperation)：当前部署不是副本集或分片集群，不支持事务
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(20) {
+		return purgeUserData(ctx, client, userId)
+	}
+	return purgeSummary{}, err
+}
+
+// purgeUserData 依次删除用户文档、该用户的评论、播放进度和审计日志，并回退评论对电影聚合评分的贡献
+// 用户不存在时不删除任何关联数据，直接返回 UsersDeleted 为 0 的结果
+func purgeUserData(ctx context.Context, client *mongo.Client, userId string) (purgeSummary, error) {
+	summary := purgeSummary{UserID: userId}
+
+	var userCollection *mongo.Collection = database.OpenCollection("users", client)
+	userResult, err := userCollection.DeleteOne(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return summary, err
+	}
+	summary.UsersDeleted = userResult.DeletedCount
+	if summary.UsersDeleted == 0 {
+		return summary, nil
+	}
+
+	var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+	// 先读取用户的评论，用于回退电影的聚合评分
+	cursor, err := reviewCollection.Find(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return summary, err
+	}
+	var reviews []models.Review
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return summary, err
+	}
+
+	reviewResult, err := reviewCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return summary, err
+	}
+	summary.ReviewsDeleted = reviewResult.DeletedCount
+
+	for _, review := range reviews {
+		if err := applyRatingDelta(ctx, movieCollection, review.ImdbID, -1, -review.Rating); err != nil {
+			return summary, err
+		}
+	}
+
+	var progressCollection *mongo.Collection = database.OpenCollection("progress", client)
+	progressResult, err := progressCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return summary, err
+	}
+	summary.ProgressDeleted = progressResult.DeletedCount
+
+	var auditCollection *mongo.Collection = database.OpenCollection("audit_log", client)
+	auditResult, err := auditCollection.DeleteMany(ctx, bson.M{"actor_id": userId})
+	if err != nil {
+		return summary, err
+	}
+	summary.AuditDeleted = auditResult.DeletedCount
+
+	return summary, nil
+}
+
+// SearchUsersByEmail 管理员按邮箱查找用户的处理器函数
+// GET /admin/users/search?email=...&match=prefix|exact，默认按前缀匹配，均不区分大小写
+// 返回 UserResponse 结构的分页结果，不包含密码哈希和令牌
+func SearchUsersByEmail(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		email := strings.TrimSpace(c.Query("email"))
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		// 转义用户输入，避免被当作正则表达式解析
+		var pattern string
+		switch c.DefaultQuery("match", "prefix") {
+		case "prefix":
+			pattern = "^" + regexp.QuoteMeta(email)
+		case "exact":
+			pattern = "^" + regexp.QuoteMeta(email) + "$"
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "match must be prefix or exact"})
+			return
+		}
+
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		filter := bson.M{"email": bson.M{"$regex": pattern, "$options": "i"}}
+		total, _, err := database.Count(ctx, userCollection, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting users"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "email", Value: 1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+		var users []models.User
+		if err := database.FindAll(ctx, userCollection, filter, &users, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching users"})
+			return
+		}
+
+		results := make([]models.UserResponse, 0, len(users))
+		for _, user := range users {
+			results = append(results, models.UserResponse{
+				UserID:          user.UserID,
+				FirstName:       user.FirstName,
+				LastName:        user.LastName,
+				Email:           user.Email,
+				Role:            user.Role,
+				FavouriteGenres: user.FavouriteGenres,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"users": results,
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		})
+	}
+}
+
+// duplicateGroup 重复电影分组：key 为分组依据的值（imdb_id 或规范化后的标题）
+type duplicateGroup struct {
+	Key    string `bson:"_id" json:"key"`
+	Count  int    `bson:"count" json:"count"`
+	Movies []struct {
+		ID        bson.ObjectID `bson:"_id" json:"_id"`
+		ImdbID    string        `bson:"imdb_id" json:"imdb_id"`
+		Title     string        `bson:"title" json:"title"`
+		CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	} `bson:"movies" json:"movies"`
+}
+
+// MigrateLegacyTokens 清理旧版本遗留在用户文档中的令牌字段的处理器函数（仅管理员）
+// 令牌早已改为通过 HttpOnly Cookie 下发，用户文档中的 token 和 refresh_token 是不再使用的敏感数据，
+// 这里对所有仍带有这两个字段的用户执行 $unset，可以重复执行
+func MigrateLegacyTokens(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		result, err := userCollection.UpdateMany(ctx,
+			bson.M{"$or": bson.A{
+				bson.M{"token": bson.M{"$exists": true}},
+				bson.M{"refresh_token": bson.M{"$exists": true}},
+			}},
+			bson.M{"$unset": bson.M{"token": "", "refresh_token": ""}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing legacy tokens"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"users_updated": result.ModifiedCount})
+	}
+}
+
+// FindDuplicateMovies 查找重复电影的处理器函数（仅管理员）
+// 默认按 imdb_id 分组，传入 by=title 时按规范化标题（去除首尾空白、忽略大小写）分组，
+// 只返回包含多个文档的分组，便于在添加唯一索引前合并或删除重复数据
+func FindDuplicateMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var groupKey any
+		switch by := c.DefaultQuery("by", "imdb_id"); by {
+		case "imdb_id":
+			groupKey = "$imdb_id"
+		case "title":
+			groupKey = bson.M{"$toLower": bson.M{"$trim": bson.M{"input": bson.M{"$ifNull": bson.A{"$title", ""}}}}}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported grouping, expected imdb_id or title"})
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":   groupKey,
+				"count": bson.M{"$sum": 1},
+				"movies": bson.M{"$push": bson.M{
+					"_id":        "$_id",
+					"imdb_id":    "$imdb_id",
+					"title":      "$title",
+					"created_at": "$created_at",
+				}},
+			}}},
+			{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		groups := []duplicateGroup{}
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error finding duplicate movies"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"groups": groups, "total_groups": len(groups)})
+	}
+}
+
+// unrankedBucketName 排名分布中没有排名的电影所在分组的名称
+const unrankedBucketName = "unranked"
+
+// rankingBucket 排名分布中的一个分组
+// 没有排名的电影归入 unrankedBucketName 分组，其 ranking_value 为 null
+type rankingBucket struct {
+	RankingName  string `bson:"_id" json:"ranking_name"`
+	RankingValue *int   `bson:"ranking_value" json:"ranking_value"`
+	Count        int    `bson:"count" json:"count"`
+}
+
+// GetRankingDistribution 获取各排名等级电影数量分布的处理器函数（仅管理员）
+// 按 ranking.ranking_name 分组计数，排名集合中配置但没有电影的等级计数为 0，
+// 缺少排名的电影计入 "unranked" 分组；结果按 ranking_value 升序，"unranked" 排在最后。
+// 用于发现 AI 是否把大部分评论都归入同一个等级（通常说明提示词有问题）
+func GetRankingDistribution(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		rankings, err := GetRankings(client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+			return
+		}
+
+		// 排名名称缺失或为空的电影分到 _id 为 null 的分组
+		rankingName := bson.M{"$ifNull": bson.A{"$ranking.ranking_name", ""}}
+		pipeline := mongo.Pipeline{
+			{{Key: "$group", Value: bson.M{
+				"_id": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{rankingName, ""}},
+					nil,
+					rankingName,
+				}},
+				"ranking_value": bson.M{"$first": "$ranking.ranking_value"},
+				"count":         bson.M{"$sum": 1},
+			}}},
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var groups []struct {
+			RankingName  *string `bson:"_id"`
+			RankingValue *int    `bson:"ranking_value"`
+			Count        int     `bson:"count"`
+		}
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating rankings"})
+			return
+		}
+
+		buckets := make(map[string]*rankingBucket, len(rankings)+1)
+		for _, ranking := range rankings {
+			value := ranking.RankingValue
+			buckets[ranking.RankingName] = &rankingBucket{RankingName: ranking.RankingName, RankingValue: &value}
+		}
+		unranked := &rankingBucket{RankingName: unrankedBucketName}
+		total := 0
+		for _, group := range groups {
+			total += group.Count
+			if group.RankingName == nil {
+				unranked.Count += group.Count
+				continue
+			}
+			bucket, ok := buckets[*group.RankingName]
+			if !ok {
+				// 电影上存在排名集合中已不存在的等级，仍然单独列出
+				bucket = &rankingBucket{RankingName: *group.RankingName, RankingValue: group.RankingValue}
+				buckets[*group.RankingName] = bucket
+			}
+			bucket.Count += group.Count
+		}
+
+		distribution := make([]rankingBucket, 0, len(buckets)+1)
+		for _, bucket := range buckets {
+			distribution = append(distribution, *bucket)
+		}
+		// 缺少 ranking_value 的等级排在有值的等级之后，同值时按名称排序保证顺序稳定
+		sortValue := func(bucket rankingBucket) int {
+			if bucket.RankingValue == nil {
+				return math.MaxInt
+			}
+			return *bucket.RankingValue
+		}
+		sort.Slice(distribution, func(i, j int) bool {
+			if a, b := sortValue(distribution[i]), sortValue(distribution[j]); a != b {
+				return a < b
+			}
+			return distribution[i].RankingName < distribution[j].RankingName
+		})
+		distribution = append(distribution, *unranked)
+
+		c.JSON(http.StatusOK, gin.H{"distribution": distribution, "total": total})
+	}
+}
+
+// GetRecentMovieChanges 获取最近修改过的电影的处理器函数（仅管理员）
+// 返回 updated_at 晚于 since（RFC3339 时间）的电影，按修改时间倒序分页，
+// 与公开的"最近添加"不同，这里同时包含新增和编辑（用户评分聚合的变化不计入）
+// 传入上一页返回的 next_cursor 作为 cursor 参数时按游标翻页并忽略 page，避免翻页期间有电影被修改导致漏读或重复；
+// 查询时多取一条判断是否还有下一页，只有还有更多电影时才返回 next_cursor
+func GetRecentMovieChanges(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		since, err := time.Parse(time.RFC3339, c.Query("since"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since is required and must be an RFC3339 timestamp"})
+			return
+		}
+
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+		cursor, ok := parseCursor(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		filter := bson.M{"updated_at": bson.M{"$gt": since}}
+		total, _, err := database.Count(ctx, movieCollection, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies"})
+			return
+		}
+
+		// 多取一条判断是否还有下一页，多出的一条不返回
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(limit + 1)
+		if cursor != nil {
+			filter = bson.M{"$and": bson.A{filter, cursorFilter("updated_at", cursor)}}
+		} else {
+			findOptions.SetSkip((page - 1) * limit)
+		}
+		movies := []models.Movie{}
+		if err := database.FindAll(ctx, movieCollection, filter, &movies, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
+			return
+		}
+
+		hasMore := int64(len(movies)) > limit
+		if hasMore {
+			movies = movies[:limit]
+		}
+
+		response := gin.H{
+			"movies": movies,
+			"since":  since,
+			"page":   page,
+			"limit":  limit,
+			"total":  total,
+		}
+		if hasMore {
+			last := movies[len(movies)-1]
+			response["next_cursor"] = encodeCursor(last.UpdatedAt, last.ID)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// exportFlushInterval 导出电影时每写出多少条刷新一次响应
+const exportFlushInterval = 100
+
+// ExportMovies 导出全部电影的处理器函数（仅管理员）
+// 从 Mongo 游标逐条解码并直接写入响应，输出为 JSON 数组，不在内存中构建完整的电影切片，
+// 无论结果多大内存占用都保持平稳。只有打开游标会重试；开始输出后出错时只能记录日志并中断响应，
+// 客户端会收到不完整的 JSON，从而能发现导出失败
+func ExportMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 10*time.Minute)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+		var cursor *mongo.Cursor
+		err := database.WithReadRetry(ctx, func(ctx context.Context) error {
+			var err error
+			cursor, err = movieCollection.Find(ctx, bson.M{}, findOptions)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+
+		c.Writer.WriteString("[")
+		count := 0
+		for cursor.Next(ctx) {
+			var movie models.Movie
+			if err := cursor.Decode(&movie); err != nil {
+				log.Printf("Error decoding movie during export: %v", err)
+				return
+			}
+			if count > 0 {
+				c.Writer.WriteString(",")
+			}
+			if err := encoder.Encode(movie); err != nil {
+				log.Printf("Error writing movie during export: %v", err)
+				return
+			}
+			count++
+			if count%exportFlushInterval == 0 {
+				c.Writer.Flush()
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			log.Printf("Error iterating movies during export: %v", err)
+			return
+		}
+		c.Writer.WriteString("]")
+	}
+}
+
+// EnsureIndexesHandler 按需创建服务依赖的索引的处理器函数（仅管理员）
+// 与启动时执行的 EnsureIndexes 逻辑相同，返回每个索引的结果；有索引创建失败时返回 500
+func EnsureIndexesHandler(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		results, err := database.EnsureIndexes(ctx, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Some indexes could not be created", "results": results})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	}
+}
+
+// ListIndexesHandler 列出各集合当前索引的处理器函数（仅管理员）
+// 用于确认部署后性能相关的索引确实存在
+func ListIndexesHandler(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		indexes, err := database.ListIndexes(ctx, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listing indexes", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, indexes)
+	}
+}
+
+// PreviewUserRecommendations 以指定用户的身份预览推荐结果的处理器函数（仅管理员）
+// 用于排查用户反馈的推荐异常：按目标用户（路径参数 id）的 favourite_genres 执行与 GetRecommendedMovies 相同的推荐逻辑，
+// 支持相同的 fields 和 include_unranked 参数，返回格式与用户看到的一致（{"movies": [...], "generated_at": ...}），
+// 预览总是实时计算，generated_at 为本次计算的时间。只读，不签发令牌也不修改任何数据
+func PreviewUserRecommendations(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		userId := c.Param("id")
+		if userId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id required"})
+			return
+		}
+
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+		includeUnranked, ok := parseIncludeUnranked(c)
+		if !ok {
+			return
+		}
+		limit, ok := parseRecommendedLimit(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		// 先确认用户存在，GetUserFavouriteGenres 对不存在的用户返回空列表
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		var user models.User
+		opts := options.FindOne().SetProjection(bson.M{"favourite_genres": 1})
+		if err := database.FindOne(ctx, userCollection, bson.M{"user_id": userId}, &user, opts); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		recommendedMovies, err := findRecommendedMovies(ctx, client, user.FavouriteGenres, limit, fields, includeUnranked)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+			return
+		}
+
+		respondRecommendations(c, cachedRecommendations{Movies: recommendedMovies, GeneratedAt: time.Now()}, fields)
+	}
+}
+
+// GetFeatureFlags 查看当前功能开关状态的处理器函数（仅管理员）
+// 返回每个开关是否开启及其来源（default、env 或 collection），开关只在启动时加载，修改后需要重启服务
+func GetFeatureFlags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"features": features.Snapshot()})
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSetUserRoleRejectsUnknownRole(t *testing.T) {
+	router := gin.New()
+	router.PUT("/admin/users/:id/role", withIdentity("admin-1", models.RoleAdmin), SetUserRole(offlineClient(t)))
+
+	w := performJSON(router, http.MethodPut, "/admin/users/u1/role", gin.H{"role": "superuser"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+}
+
+func TestSetUserRoleRequiresAdmin(t *testing.T) {
+	router := gin.New()
+	router.PUT("/admin/users/:id/role", withIdentity("user-1", models.RoleUser), SetUserRole(offlineClient(t)))
+
+	w := performJSON(router, http.MethodPut, "/admin/users/user-1/role", gin.H{"role": "admin"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusUnauthorized, w.Body.String())
+	}
+}
+
+func TestSetUserRoleNormalizesAndRevokesTokens(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users := database.OpenCollection("users", client)
+	if _, err := users.InsertOne(ctx, bson.M{"user_id": "u1", "role": models.RoleUser, "token_version": 2}); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	router := gin.New()
+	router.PUT("/admin/users/:id/role", withIdentity("admin-1", models.RoleAdmin), SetUserRole(client))
+
+	w := performJSON(router, http.MethodPut, "/admin/users/u1/role", gin.H{"role": " admin "})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"user_id": "u1"}).Decode(&user); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Errorf("role = %q, want %q", user.Role, models.RoleAdmin)
+	}
+	if user.TokenVersion != 3 {
+		t.Errorf("token_version = %d, want 3", user.TokenVersion)
+	}
+}
+
+func TestAdminPurgeUser(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userId := createTestUser(t, client, "purge@example.com", "password123")
+	movies := database.OpenCollection("movies", client)
+	reviews := database.OpenCollection("reviews", client)
+	audit := database.OpenCollection("audit_log", client)
+	if _, err := movies.InsertOne(ctx, models.Movie{ImdbID: "tt1", Title: "Rated", UserRatingCount: 2, UserRatingSum: 9, UserRatingAvg: 4.5}); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+	for _, review := range []models.Review{
+		{UserID: userId, ImdbID: "tt1", Rating: 5},
+		{UserID: "someone-else", ImdbID: "tt1", Rating: 4},
+	} {
+		if _, err := reviews.InsertOne(ctx, review); err != nil {
+			t.Fatalf("insert review: %v", err)
+		}
+	}
+	for _, entry := range []models.AuditEntry{
+		{Action: models.AuditActionUpdate, ActorID: userId, TargetImdbID: "tt1"},
+		{Action: models.AuditActionUpdate, ActorID: "someone-else", TargetImdbID: "tt1"},
+	} {
+		if _, err := audit.InsertOne(ctx, entry); err != nil {
+			t.Fatalf("insert audit entry: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.DELETE("/admin/users/:id", withIdentity("admin-1", models.RoleAdmin), AdminPurgeUser(client))
+
+	// 不存在的用户：返回 404，不触碰任何关联数据
+	if w := performJSON(router, http.MethodDelete, "/admin/users/"+userId+"-missing", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("purge missing user status = %d, want 404", w.Code)
+	}
+	var movie models.Movie
+	if err := movies.FindOne(ctx, bson.M{"imdb_id": "tt1"}).Decode(&movie); err != nil {
+		t.Fatalf("find movie: %v", err)
+	}
+	if movie.UserRatingCount != 2 || movie.UserRatingSum != 9 {
+		t.Errorf("missing-user purge changed ratings to count %d sum %d", movie.UserRatingCount, movie.UserRatingSum)
+	}
+
+	w := performJSON(router, http.MethodDelete, "/admin/users/"+userId, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("purge status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	if n, _ := reviews.CountDocuments(ctx, bson.M{}); n != 1 {
+		t.Errorf("reviews left = %d, want 1", n)
+	}
+	if n, _ := audit.CountDocuments(ctx, bson.M{"actor_id": userId}); n != 0 {
+		t.Errorf("audit entries by purged user = %d, want 0", n)
+	}
+	if n, _ := audit.CountDocuments(ctx, bson.M{}); n != 1 {
+		t.Errorf("audit entries left = %d, want 1", n)
+	}
+	if err := movies.FindOne(ctx, bson.M{"imdb_id": "tt1"}).Decode(&movie); err != nil {
+		t.Fatalf("find movie: %v", err)
+	}
+	if movie.UserRatingCount != 1 || movie.UserRatingSum != 4 {
+		t.Errorf("ratings after purge = count %d sum %d, want 1 and 4", movie.UserRatingCount, movie.UserRatingSum)
+	}
+}
+
+func TestRecentMovieChangesCursorStopsOnLastPage(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	router := gin.New()
+	router.GET("/admin/movies/recent-changes", withIdentity("admin-1", models.RoleAdmin), GetRecentMovieChanges(client))
+
+	type page struct {
+		Movies     []models.Movie `json:"movies"`
+		NextCursor string         `json:"next_cursor"`
+	}
+	fetch := func(query string) page {
+		t.Helper()
+		w := performJSON(router, http.MethodGet, "/admin/movies/recent-changes?since=2000-01-01T00:00:00Z&"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d; body %s", query, w.Code, w.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("decode %s: %v; body %s", query, err, w.Body.String())
+		}
+		return p
+	}
+
+	// 共 5 部电影，恰好取完全部电影时不返回指向空页的游标
+	if p := fetch("limit=5"); len(p.Movies) != 5 || p.NextCursor != "" {
+		t.Errorf("limit=5: %d movies, next_cursor %q; want 5 and no cursor", len(p.Movies), p.NextCursor)
+	}
+
+	first := fetch("limit=3")
+	if len(first.Movies) != 3 || first.NextCursor == "" {
+		t.Fatalf("limit=3: %d movies, next_cursor %q; want 3 and a cursor", len(first.Movies), first.NextCursor)
+	}
+	second := fetch("limit=3&cursor=" + url.QueryEscape(first.NextCursor))
+	if len(second.Movies) != 2 || second.NextCursor != "" {
+		t.Errorf("second page: %d movies, next_cursor %q; want 2 and no cursor", len(second.Movies), second.NextCursor)
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/cache"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// genreCount 某个类型的电影数量
+type genreCount struct {
+	GenreName string `bson:"_id" json:"genre_name"`
+	Count     int    `bson:"count" json:"count"`
+}
+
+// rankingCount 某个排名等级的电影数量，缺少排名的电影 RankingName 为空
+type rankingCount struct {
+	RankingName  string `bson:"_id" json:"ranking_name"`
+	RankingValue *int   `bson:"ranking_value" json:"ranking_value"`
+	Count        int    `bson:"count" json:"count"`
+}
+
+// catalogAnalytics 目录统计报告
+type catalogAnalytics struct {
+	TotalMovies        int            `json:"total_movies"`
+	NewMoviesThisMonth int            `json:"new_movies_this_month"`
+	ByGenre            []genreCount   `json:"by_genre"`
+	ByRanking          []rankingCount `json:"by_ranking"`
+	Ratings            struct {
+		Average     float64 `json:"average"`
+		RatedMovies int     `json:"rated_movies"`
+	} `json:"ratings"`
+	Reviews struct {
+		Total     int64  `json:"total"`
+		CountType string `json:"count_type"`
+	} `json:"reviews"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// GetCatalogAnalytics 获取目录统计报告的处理器函数（仅管理员）
+// 一次返回电影总数、按类型和排名的分布、整体用户平均评分（按评论数加权）、评论总数和本月（UTC）新增电影数，
+// 用于月度报告和仪表盘。电影相关统计通过一次 $facet 聚合完成；
+// 结果缓存 ANALYTICS_CACHE_TTL_SECONDS 秒（默认 300 秒），generated_at 为实际计算时间，传入 refresh=true 重新计算
+func GetCatalogAnalytics(client *mongo.Client) gin.HandlerFunc {
+	ttl := 300 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("ANALYTICS_CACHE_TTL_SECONDS")); err == nil && value > 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	analyticsCache := cache.New[catalogAnalytics](ttl, 1)
+
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		refresh, ok := parseBoolQuery(c, "refresh", false)
+		if !ok {
+			return
+		}
+		if !refresh {
+			if report, ok := analyticsCache.Get("analytics"); ok {
+				c.Header("X-Cache", "HIT")
+				c.JSON(http.StatusOK, report)
+				return
+			}
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		report, err := computeCatalogAnalytics(ctx, client)
+		if err != nil {
+			logger.Error("error computing catalog analytics", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing analytics"})
+			return
+		}
+
+		analyticsCache.Set("analytics", report)
+		c.Header("X-Cache", "MISS")
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// computeCatalogAnalytics 计算目录统计报告
+func computeCatalogAnalytics(ctx context.Context, client *mongo.Client) (catalogAnalytics, error) {
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"new_this_month": bson.A{
+				bson.M{"$match": bson.M{"created_at": bson.M{"$gte": monthStart}}},
+				bson.M{"$count": "count"},
+			},
+			"by_genre": bson.A{
+				bson.M{"$unwind": "$genre"},
+				bson.M{"$group": bson.M{"_id": "$genre.genre_name", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"by_ranking": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":           bson.M{"$ifNull": bson.A{"$ranking.ranking_name", ""}},
+					"ranking_value": bson.M{"$first": "$ranking.ranking_value"},
+					"count":         bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.D{{Key: "ranking_value", Value: 1}, {Key: "_id", Value: 1}}},
+			},
+			"ratings": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":          nil,
+					"rating_sum":   bson.M{"$sum": "$user_rating_sum"},
+					"rating_count": bson.M{"$sum": "$user_rating_count"},
+					"rated_movies": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{"$user_rating_count", 0}}, 1, 0,
+					}}},
+				}},
+			},
+		}}},
+	}
+
+	type countResult struct {
+		Count int `bson:"count"`
+	}
+	var facets []struct {
+		Total        []countResult  `bson:"total"`
+		NewThisMonth []countResult  `bson:"new_this_month"`
+		ByGenre      []genreCount   `bson:"by_genre"`
+		ByRanking    []rankingCount `bson:"by_ranking"`
+		Ratings      []struct {
+			RatingSum   int `bson:"rating_sum"`
+			RatingCount int `bson:"rating_count"`
+			RatedMovies int `bson:"rated_movies"`
+		} `bson:"ratings"`
+	}
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+	if err := database.AggregateAll(ctx, movieCollection, pipeline, &facets); err != nil {
+		return catalogAnalytics{}, err
+	}
+
+	var report catalogAnalytics
+	report.ByGenre = []genreCount{}
+	report.ByRanking = []rankingCount{}
+	if len(facets) > 0 {
+		facet := facets[0]
+		if len(facet.Total) > 0 {
+			report.TotalMovies = facet.Total[0].Count
+		}
+		if len(facet.NewThisMonth) > 0 {
+			report.NewMoviesThisMonth = facet.NewThisMonth[0].Count
+		}
+		if facet.ByGenre != nil {
+			report.ByGenre = facet.ByGenre
+		}
+		if facet.ByRanking != nil {
+			report.ByRanking = facet.ByRanking
+		}
+		if len(facet.Ratings) > 0 && facet.Ratings[0].RatingCount > 0 {
+			ratings := facet.Ratings[0]
+			report.Ratings.Average = float64(ratings.RatingSum) / float64(ratings.RatingCount)
+			report.Ratings.RatedMovies = ratings.RatedMovies
+		}
+	}
+
+	var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+	total, countType, err := database.Count(ctx, reviewCollection, bson.M{})
+	if err != nil {
+		return catalogAnalytics{}, err
+	}
+	report.Reviews.Total = total
+	report.Reviews.CountType = countType
+
+	report.GeneratedAt = time.Now()
+	return report, nil
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// recordAudit 写入一条审计日志
+// 审计日志写入失败只记录日志，不影响业务请求本身
+func recordAudit(ctx context.Context, client *mongo.Client, action, actorId, imdbId string, details bson.M) {
+	entry := models.AuditEntry{
+		Action:       action,
+		ActorID:      actorId,
+		TargetImdbID: imdbId,
+		Details:      details,
+		CreatedAt:    time.Now(),
+	}
+
+	var auditCollection *mongo.Collection = database.OpenCollection("audit_log", client)
+	if _, err := auditCollection.InsertOne(ctx, entry); err != nil {
+		log.Printf("Error recording audit entry (%s %s): %v", action, imdbId, err)
+	}
+}
+
+// GetMovieAudit 获取单部电影审计记录的处理器函数（仅管理员）
+// 返回目标为该 imdb_id 的审计记录，按时间倒序分页，支持 page（默认 1）和 limit（默认 20，最大 100）参数
+func GetMovieAudit(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var auditCollection *mongo.Collection = database.OpenCollection("audit_log", client)
+
+		filter := bson.M{"target_imdb_id": movieId}
+		total, _, err := database.Count(ctx, auditCollection, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting audit entries"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+		entries := []models.AuditEntry{}
+		if err := database.FindAll(ctx, auditCollection, filter, &entries, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching audit entries"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"entries": entries,
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+		})
+	}
+}
+
+// auditActions 允许作为过滤条件的审计操作类型
+var auditActions = map[string]bool{
+	models.AuditActionCreate: true,
+	models.AuditActionUpdate: true,
+	models.AuditActionDelete: true,
+	models.AuditActionRank:   true,
+}
+
+// parseAuditTime 解析 RFC3339 格式的时间参数，参数为空时返回 nil
+// 格式错误时返回 400 并返回 false
+func parseAuditTime(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC3339 timestamp"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// ListAuditEntries 查询审计日志的处理器函数（仅管理员）
+// 支持以下过滤参数，可以任意组合：action（create/update/delete/rank）、actor（操作者 user_id）、
+// imdb_id（目标电影）、from 和 to（RFC3339 时间，按 created_at 过滤，包含边界）。
+// 结果按时间倒序，使用 limit（默认 20，最大 100）和上一页返回的 next_cursor 翻页，没有更多条目时不返回 next_cursor
+// 不带任何过滤参数时 total 来自集合元数据的估算值，count_type 为 estimated，否则为精确计数 exact
+func ListAuditEntries(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		filter := bson.M{}
+		if action := c.Query("action"); action != "" {
+			if !auditActions[action] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of create, update, delete, rank"})
+				return
+			}
+			filter["action"] = action
+		}
+		if actor := c.Query("actor"); actor != "" {
+			filter["actor_id"] = actor
+		}
+		if imdbId := c.Query("imdb_id"); imdbId != "" {
+			filter["target_imdb_id"] = imdbId
+		}
+
+		from, ok := parseAuditTime(c, "from")
+		if !ok {
+			return
+		}
+		to, ok := parseAuditTime(c, "to")
+		if !ok {
+			return
+		}
+		if from != nil && to != nil && from.After(*to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+		createdAt := bson.M{}
+		if from != nil {
+			createdAt["$gte"] = *from
+		}
+		if to != nil {
+			createdAt["$lte"] = *to
+		}
+		if len(createdAt) > 0 {
+			filter["created_at"] = createdAt
+		}
+
+		_, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+		cursor, ok := parseCursor(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var auditCollection *mongo.Collection = database.OpenCollection("audit_log", client)
+
+		total, countType, err := database.Count(ctx, auditCollection, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting audit entries"})
+			return
+		}
+
+		pageFilter := filter
+		if cursor != nil {
+			pageFilter = bson.M{"$and": bson.A{filter, cursorFilter("created_at", cursor)}}
+		}
+		// 多取一条判断是否还有下一页，多出的一条不返回
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(limit + 1)
+		entries := []models.AuditEntry{}
+		if err := database.FindAll(ctx, auditCollection, pageFilter, &entries, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching audit entries"})
+			return
+		}
+
+		hasMore := int64(len(entries)) > limit
+		if hasMore {
+			entries = entries[:limit]
+		}
+
+		response := gin.H{
+			"entries":    entries,
+			"limit":      limit,
+			"total":      total,
+			"count_type": countType,
+		}
+		if hasMore {
+			last := entries[len(entries)-1]
+			response["next_cursor"] = encodeCursor(last.CreatedAt, last.ID)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditTotalsReportCountType(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	auditCollection := database.OpenCollection("audit_log", client)
+	for _, imdbId := range []string{"tt1", "tt1", "tt2"} {
+		entry := models.AuditEntry{Action: models.AuditActionUpdate, ActorID: "admin-1", TargetImdbID: imdbId, CreatedAt: time.Now()}
+		if _, err := auditCollection.InsertOne(ctx, entry); err != nil {
+			t.Fatalf("insert audit entry: %v", err)
+		}
+	}
+
+	router := gin.New()
+	admin := withIdentity("admin-1", models.RoleAdmin)
+	router.GET("/audit", admin, ListAuditEntries(client))
+	router.GET("/movie/:imdb_id/audit", admin, GetMovieAudit(client))
+
+	for _, tc := range []struct {
+		path          string
+		wantTotal     int64
+		wantCountType any
+	}{
+		// 不带过滤条件的总数来自集合元数据
+		{"/audit", 3, database.CountEstimated},
+		{"/audit?imdb_id=tt1", 2, database.CountExact},
+		// 单部电影的审计记录总是带过滤条件，不返回 count_type
+		{"/movie/tt1/audit", 2, nil},
+	} {
+		w := performJSON(router, http.MethodGet, tc.path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d; body %s", tc.path, w.Code, w.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode %s: %v", tc.path, err)
+		}
+		if total, _ := body["total"].(float64); int64(total) != tc.wantTotal {
+			t.Errorf("GET %s total = %v, want %d", tc.path, body["total"], tc.wantTotal)
+		}
+		if got := body["count_type"]; got != tc.wantCountType {
+			t.Errorf("GET %s count_type = %v, want %v", tc.path, got, tc.wantCountType)
+		}
+	}
+}
+
+func TestListAuditEntriesCursorStopsOnLastPage(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := range 4 {
+		recordAudit(ctx, client, models.AuditActionUpdate, "admin-1", fmt.Sprintf("tt%d", i), nil)
+	}
+
+	router := gin.New()
+	router.GET("/audit", withIdentity("admin-1", models.RoleAdmin), ListAuditEntries(client))
+
+	type page struct {
+		Entries    []models.AuditEntry `json:"entries"`
+		NextCursor string              `json:"next_cursor"`
+	}
+	fetch := func(query string) page {
+		t.Helper()
+		w := performJSON(router, http.MethodGet, "/audit?"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /audit?%s status = %d; body %s", query, w.Code, w.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("decode: %v; body %s", err, w.Body.String())
+		}
+		return p
+	}
+
+	// 条目数恰好是 limit 的倍数时，最后一页不返回指向空页的游标
+	first := fetch("limit=2")
+	if len(first.Entries) != 2 || first.NextCursor == "" {
+		t.Fatalf("first page: %d entries, next_cursor %q; want 2 and a cursor", len(first.Entries), first.NextCursor)
+	}
+	second := fetch("limit=2&cursor=" + url.QueryEscape(first.NextCursor))
+	if len(second.Entries) != 2 || second.NextCursor != "" {
+		t.Errorf("second page: %d entries, next_cursor %q; want 2 and no cursor", len(second.Entries), second.NextCursor)
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// respondBindError 根据 ShouldBindJSON 的错误类型返回具体的 400 响应
+// 区分空请求体、JSON 语法错误、字段类型错误和校验失败，方便客户端定位问题
+func respondBindError(c *gin.Context, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var validationErrs validator.ValidationErrors
+
+	switch {
+	case errors.Is(err, io.EOF):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body is empty", "code": "EMPTY_BODY"})
+	case errors.As(err, &syntaxErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Malformed JSON",
+			"code":    "INVALID_JSON",
+			"details": fmt.Sprintf("syntax error at byte offset %d", syntaxErr.Offset),
+		})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Malformed JSON", "code": "INVALID_JSON", "details": "unexpected end of JSON input"})
+	case errors.As(err, &typeErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Invalid field type",
+			"code":     "INVALID_FIELD_TYPE",
+			"field":    typeErr.Field,
+			"expected": typeErr.Type.String(),
+			"got":      typeErr.Value,
+		})
+	case errors.As(err, &validationErrs):
+		respondValidationError(c, err)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data", "code": "INVALID_INPUT", "details": err.Error()})
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// 预计算缓存存放在 computed_cache 集合中，每种缓存一个文档，_id 为缓存名
+const (
+	trendingMoviesCacheKey = "trending_movies"
+	featuredGenresCacheKey = "featured_genres"
+)
+
+// 热门电影的统计窗口和数量
+const (
+	trendingWindow = 7 * 24 * time.Hour
+	trendingLimit  = 20
+)
+
+type trendingMoviesCache struct {
+	Movies     []models.Movie `bson:"movies" json:"movies"`
+	ComputedAt time.Time      `bson:"computed_at" json:"computed_at"`
+}
+
+type featuredGenresCache struct {
+	Genres     []models.FeaturedGenre `bson:"genres" json:"genres"`
+	ComputedAt time.Time              `bson:"computed_at" json:"computed_at"`
+}
+
+// StartCacheScheduler 启动后台任务，按 interval 周期性重新计算热门电影和类型卡片缓存
+// 启动时立即计算一次；ctx 取消后任务退出，返回的通道在任务完全停止后关闭，便于优雅关闭时等待
+func StartCacheScheduler(ctx context.Context, client *mongo.Client, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			refreshComputedCaches(ctx, client)
+
+			select {
+			case <-ctx.Done():
+				log.Println("Cache scheduler stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return done
+}
+
+// refreshComputedCaches 重新计算所有预计算缓存，单项失败只记录日志
+func refreshComputedCaches(ctx context.Context, client *mongo.Client) {
+	jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	if movies, err := computeTrendingMovies(jobCtx, client); err != nil {
+		log.Printf("Error computing trending movies: %v", err)
+	} else if err := writeComputedCache(jobCtx, client, trendingMoviesCacheKey, trendingMoviesCache{Movies: movies, ComputedAt: time.Now()}); err != nil {
+		log.Printf("Error writing trending movies cache: %v", err)
+	}
+
+	if genres, err := computeFeaturedGenres(jobCtx, client); err != nil {
+		log.Printf("Error computing featured genres: %v", err)
+	} else if err := writeComputedCache(jobCtx, client, featuredGenresCacheKey, featuredGenresCache{Genres: genres, ComputedAt: time.Now()}); err != nil {
+		log.Printf("Error writing featured genres cache: %v", err)
+	}
+}
+
+// computeTrendingMovies 统计最近 7 天内评论最多的电影
+func computeTrendingMovies(ctx context.Context, client *mongo.Client) ([]models.Movie, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"updated_at": bson.M{"$gte": time.Now().Add(-trendingWindow)}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$imdb_id", "activity": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "activity", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: trendingLimit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "movies",
+			"localField":   "_id",
+			"foreignField": "imdb_id",
+			"as":           "movie",
+		}}},
+		{{Key: "$unwind", Value: "$movie"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie"}}},
+	}
+
+	var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+	movies := []models.Movie{}
+	if err := database.AggregateAll(ctx, reviewCollection, pipeline, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// writeComputedCache 以缓存名为 _id 整体替换缓存文档
+func writeComputedCache(ctx context.Context, client *mongo.Client, key string, value any) error {
+	var cacheCollection *mongo.Collection = database.OpenCollection("computed_cache", client)
+	_, err := cacheCollection.ReplaceOne(ctx, bson.M{"_id": key}, value, options.Replace().SetUpsert(true))
+	return err
+}
+
+// readComputedCache 读取缓存文档，缓存尚未计算时返回 mongo.ErrNoDocuments
+func readComputedCache(ctx context.Context, client *mongo.Client, key string, result any) error {
+	var cacheCollection *mongo.Collection = database.OpenCollection("computed_cache", client)
+	return database.FindOne(ctx, cacheCollection, bson.M{"_id": key}, result)
+}
+
+// GetTrendingMovies 获取热门电影的处理器函数
+// 只读取后台任务预先计算的结果，computed_at 为最近一次计算的时间
+func GetTrendingMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var cached trendingMoviesCache
+		err := readComputedCache(ctx, client, trendingMoviesCacheKey, &cached)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trending movies have not been computed yet"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching trending movies"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cached)
+	}
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// selectableMovieFields 可以通过 fields 参数选择的电影字段（json 名称）
+var selectableMovieFields = map[string]bool{
+	"_id":               true,
+	"imdb_id":           true,
+	"title":             true,
+	"description":       true,
+	"poster_path":       true,
+	"youtube_id":        true,
+	"genre":             true,
+	"tags":              true,
+	"cast":              true,
+	"directors":         true,
+	"admin_review":      true,
+	"ranking":           true,
+	"created_at":        true,
+	"updated_at":        true,
+	"release_date":      true,
+	"locked":            true,
+	"user_rating_avg":   true,
+	"user_rating_count": true,
+}
+
+// requiredMovieFields 无论选择了哪些字段都会返回的字段，保证客户端能定位电影并渲染排名徽章
+var requiredMovieFields = []string{"imdb_id", "ranking"}
+
+// parseMovieFields 解析逗号分隔的 fields 查询参数
+// 未传入时返回 nil 表示返回完整文档；包含未知字段时写入 400 响应并返回 ok=false
+func parseMovieFields(c *gin.Context) (fields []string, ok bool) {
+	value := c.Query("fields")
+	if value == "" {
+		return nil, true
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range append(strings.Split(value, ","), requiredMovieFields...) {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		if !selectableMovieFields[field] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown field in fields parameter", "field": field})
+			return nil, false
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	return fields, true
+}
+
+// movieFieldsProjection 将选择的字段转换为 $project 阶段的包含式投影
+func movieFieldsProjection(fields []string) bson.M {
+	projection := bson.M{"_id": 0}
+	for _, field := range fields {
+		projection[field] = 1
+	}
+	return projection
+}
+
+// selectMovieFields 只保留选择的字段，避免未查询的字段以零值出现在响应中
+func selectMovieFields(movies []models.Movie, fields []string) ([]map[string]any, error) {
+	selected := make([]map[string]any, 0, len(movies))
+	for _, movie := range movies {
+		data, err := json.Marshal(movie)
+		if err != nil {
+			return nil, err
+		}
+		var full map[string]any
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, err
+		}
+
+		item := make(map[string]any, len(fields))
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				item[field] = value
+			}
+		}
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
+
+// respondMovies 返回电影列表，选择了字段时只返回这些字段
+// 通过 /api/v1 路由访问时返回结构化表示，选择的字段按所属分组嵌套
+func respondMovies(c *gin.Context, movies []models.Movie, fields []string) {
+	payload, err := moviesPayload(c, movies, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding movies"})
+		return
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+// moviesPayload 按 respondMovies 的规则构建电影列表的响应内容，供需要把列表嵌入其他响应的接口使用
+func moviesPayload(c *gin.Context, movies []models.Movie, fields []string) (any, error) {
+	if fields == nil {
+		if !structuredMovies(c) {
+			return movies, nil
+		}
+		structured := make([]models.StructuredMovie, 0, len(movies))
+		for _, movie := range movies {
+			structured = append(structured, structuredMovie(c, movie))
+		}
+		return structured, nil
+	}
+
+	selected, err := selectMovieFields(movies, fields)
+	if err != nil {
+		return nil, err
+	}
+	if structuredMovies(c) {
+		showEditorial := editorialVisible(c)
+		for i, item := range selected {
+			selected[i] = groupMovieFields(item, showEditorial)
+		}
+	}
+	return selected, nil
+}
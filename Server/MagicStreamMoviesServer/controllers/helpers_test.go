@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// offlineClient 返回一个不会真正连接的客户端，用于在访问数据库之前就返回的处理器路径
+func offlineClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return client
+}
+
+// testClient 连接 MONGODB_TEST_URL 指定的 MongoDB，并使用一个测试结束后删除的独立数据库
+// 未设置 MONGODB_TEST_URL 时跳过测试
+func testClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+
+	name := fmt.Sprintf("magicstream_test_%d", time.Now().UnixNano())
+	client := database.Connect(database.Config{MongoURL: url, DatabaseName: name})
+	if client == nil {
+		t.Fatalf("connect to %s failed", url)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = client.Database(name).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return client
+}
+
+// withIdentity 模拟认证中间件，在上下文中写入用户 ID 和角色
+func withIdentity(userId, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userID", userId)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// performJSON 向路由发送 JSON 请求并返回响应
+func performJSON(router http.Handler, method, path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
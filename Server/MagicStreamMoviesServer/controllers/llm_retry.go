@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// ErrLLMUnavailable AI 服务在重试后仍然超时或不可用，调用方应返回 503 而不是 500
+var ErrLLMUnavailable = errors.New("llm provider unavailable")
+
+// initialLLMBackoff 第一次重试前的等待时间，之后每次翻倍
+const initialLLMBackoff = 500 * time.Millisecond
+
+// llmBackoffTimer 重试前等待退避时间的计时器，测试中替换以记录退避时间而不真正等待
+var llmBackoffTimer = time.After
+
+// serverErrorStatus 匹配 OpenAI 兼容接口返回的 5xx 状态码错误
+var serverErrorStatus = regexp.MustCompile(`status code: 5\d\d`)
+
+// llmTimeout 返回单次 AI 调用的超时时间
+// 优先读取环境变量 LLM_TIMEOUT_SECONDS，默认 15 秒
+func llmTimeout() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("LLM_TIMEOUT_SECONDS")); err == nil && value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// llmMaxAttempts 返回 AI 调用的最大尝试次数（包括第一次）
+// 优先读取环境变量 LLM_MAX_ATTEMPTS，默认 3
+func llmMaxAttempts() int {
+	if value, err := strconv.Atoi(os.Getenv("LLM_MAX_ATTEMPTS")); err == nil && value > 0 {
+		return value
+	}
+	return 3
+}
+
+// llmCallBudget 返回一次 callLLMWithRetry 在重试耗尽前最多花费的时间：每次尝试的超时加上各次退避
+// 默认配置下约为 3×15 秒 + 0.5 秒 + 1 秒
+func llmCallBudget() time.Duration {
+	attempts := llmMaxAttempts()
+	budget := time.Duration(attempts) * llmTimeout()
+	backoff := initialLLMBackoff
+	for i := 1; i < attempts; i++ {
+		budget += backoff
+		backoff *= 2
+	}
+	return budget
+}
+
+// callLLMWithRetry 以单次超时 llmTimeout 调用 call，超时、限流和 5xx 等瞬时错误按指数退避重试，最多 llmMaxAttempts 次
+// 不可重试的错误（如 API 密钥无效）直接返回；重试耗尽后返回包装了最后一次错误的 ErrLLMUnavailable
+func callLLMWithRetry(ctx context.Context, call func(ctx context.Context) (string, error)) (string, error) {
+	attempts := llmMaxAttempts()
+	backoff := initialLLMBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, llmTimeout())
+		response, err := call(attemptCtx)
+		cancel()
+		if err == nil {
+			return response, nil
+		}
+		if ctx.Err() != nil || !isRetryableLLMError(err) {
+			return "", err
+		}
+		if attempt >= attempts {
+			return "", fmt.Errorf("%w after %d attempts: %w", ErrLLMUnavailable, attempt, err)
+		}
+
+		logger.Warn("retrying LLM call", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w: %w", ErrLLMUnavailable, err)
+		case <-llmBackoffTimer(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableLLMError 判断 AI 调用错误是否为瞬时错误
+func isRetryableLLMError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || serverErrorStatus.MatchString(err.Error()) {
+		return true
+	}
+	mapped := openai.MapError(err)
+	return llms.IsTimeoutError(mapped) || llms.IsRateLimitError(mapped) || llms.IsProviderUnavailableError(mapped)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordBackoffs 替换退避计时器，记录每次请求的等待时间并立即返回
+func recordBackoffs(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	original := llmBackoffTimer
+	llmBackoffTimer = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { llmBackoffTimer = original })
+	return &waits
+}
+
+func TestCallLLMWithRetryExhaustsAttempts(t *testing.T) {
+	t.Setenv("LLM_MAX_ATTEMPTS", "3")
+	waits := recordBackoffs(t)
+
+	attempts := 0
+	var lastErr error
+	_, err := callLLMWithRetry(context.Background(), func(ctx context.Context) (string, error) {
+		attempts++
+		lastErr = fmt.Errorf("API returned unexpected status code: 503 (attempt %d)", attempts)
+		return "", lastErr
+	})
+
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	if want := []time.Duration{initialLLMBackoff, 2 * initialLLMBackoff}; !reflect.DeepEqual(*waits, want) {
+		t.Errorf("backoffs = %v, want %v", *waits, want)
+	}
+	if !errors.Is(err, ErrLLMUnavailable) {
+		t.Errorf("error %v does not wrap ErrLLMUnavailable", err)
+	}
+	if !errors.Is(err, lastErr) {
+		t.Errorf("error %v does not wrap the last attempt's error %v", err, lastErr)
+	}
+}
+
+func TestCallLLMWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	t.Setenv("LLM_MAX_ATTEMPTS", "3")
+	waits := recordBackoffs(t)
+
+	attempts := 0
+	response, err := callLLMWithRetry(context.Background(), func(ctx context.Context) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", context.DeadlineExceeded
+		}
+		return "Excellent", nil
+	})
+
+	if err != nil || response != "Excellent" {
+		t.Fatalf("callLLMWithRetry = %q, %v; want Excellent, nil", response, err)
+	}
+	if attempts != 3 || len(*waits) != 2 {
+		t.Errorf("attempts = %d, backoffs = %v; want 3 attempts and 2 backoffs", attempts, *waits)
+	}
+}
+
+func TestCallLLMWithRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	t.Setenv("LLM_MAX_ATTEMPTS", "3")
+	waits := recordBackoffs(t)
+
+	permanent := errors.New("API returned unexpected status code: 401: invalid api key")
+	attempts := 0
+	_, err := callLLMWithRetry(context.Background(), func(ctx context.Context) (string, error) {
+		attempts++
+		return "", permanent
+	})
+
+	if attempts != 1 || len(*waits) != 0 {
+		t.Errorf("attempts = %d, backoffs = %v; want a single attempt", attempts, *waits)
+	}
+	if !errors.Is(err, permanent) || errors.Is(err, ErrLLMUnavailable) {
+		t.Errorf("error = %v, want the permanent error without ErrLLMUnavailable", err)
+	}
+}
+
+func TestLLMCallBudget(t *testing.T) {
+	t.Setenv("LLM_MAX_ATTEMPTS", "")
+	t.Setenv("LLM_TIMEOUT_SECONDS", "")
+	if got, want := llmCallBudget(), 46500*time.Millisecond; got != want {
+		t.Errorf("default budget = %v, want %v", got, want)
+	}
+
+	t.Setenv("LLM_MAX_ATTEMPTS", "1")
+	t.Setenv("LLM_TIMEOUT_SECONDS", "5")
+	if got, want := llmCallBudget(), 5*time.Second; got != want {
+		t.Errorf("single attempt budget = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"log/slog"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/logging"
+)
+
+// logger 控制器使用的日志记录器，级别由 LOG_LEVEL_CONTROLLERS 或 LOG_LEVEL 配置
+var logger = logging.New("controllers")
+
+// SetLogger 替换控制器使用的日志记录器，供测试断言日志输出或调整输出方式
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
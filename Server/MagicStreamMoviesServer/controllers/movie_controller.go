@@ -3,57 +3,205 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/cache"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"github.com/joho/godotenv"
-	"github.com/tmc/langchaingo/llms/openai"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/sync/singleflight"
 )
 
 // 全局变量定义
-var validate = validator.New() // 数据验证器实例
+var validate = newValidator() // 数据验证器实例
 
-// GetMovies 获取所有电影的处理器函数
-// 返回所有存储在数据库中的电影列表
+// ErrNoRankings 排名集合中没有可供 AI 选择的排名等级
+var ErrNoRankings = errors.New("no rankings configured")
+
+// ErrUnrecognizedRanking AI 返回的内容无法对应到任何排名等级
+var ErrUnrecognizedRanking = errors.New("unrecognized ranking in AI response")
+
+// GetMovies 获取电影列表的处理器函数
+// 使用基于 imdb_id 的游标分页：limit 为单页条数（默认和上限均为 MOVIES_PAGE_MAX_LIMIT，默认 50，非数字返回 400），
+// after 为上一页最后一部电影的 imdb_id；响应为 {"movies": [...], "next_cursor": "..."}（/movies 和 /api/v1/movies 相同，
+// 早期版本直接返回数组，见 README 的 API 说明），next_cursor 为本页最后一部电影的 imdb_id，没有更多电影时为空字符串。
+// 查询时多取一条用于判断是否还有下一页，恰好取完最后一页时不会返回指向空页的游标；
+// 游标只适用于 imdb_id 排序，按其它字段排序时 next_cursor 始终为空字符串，避免返回一个 after 会拒绝的游标
+// 支持 filterableMovieFields 中的过滤参数：传入 tag 时只返回带有该标签的电影，传入 genre 时只返回该类型的电影
+// （不区分大小写，可重复传入 genre=Action&genre=Drama 返回属于任一类型的电影，没有匹配时返回空数组），
+// 传入 actor 或 director 时只返回该演员参演或该导演执导的电影（姓名不区分大小写）
+// 传入 fields=title,poster_path 等逗号分隔的字段时只返回这些字段（imdb_id 和 ranking 始终返回）
+// 传入 truncate_description=N 时描述被截断为 N 个字符并追加省略号，以减小列表响应体积
+// 传入 exclude_coming_soon=true 时排除 release_date 晚于当前时间的即将上线电影
+// 支持 sort=field:asc|desc 查询参数，字段必须在 sortableMovieFields 中，例如：
+//   - imdb_id:asc（默认）：按 imdb_id 升序，after 游标只能与 imdb_id 排序一起使用
+//   - created_at:desc：按创建时间倒序
+//   - user_rating_avg:desc：按用户平均评分倒序，评分数量不足 MIN_USER_RATING_COUNT（默认 5）的电影排在后面，
+//     同分时按评分数量倒序，避免只有一条五星评价的电影排在上百条 4.8 分的电影前面
 func GetMovies(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		sortSpec, ok := parseMovieSort(c, "imdb_id:asc")
+		if !ok {
+			return
+		}
+		filter, ok := parseMovieFilters(c)
+		if !ok {
+			return
+		}
+
+		maxLimit := moviesPageMaxLimit()
+		limit := maxLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = min(parsed, maxLimit)
+		}
+		if after := strings.TrimSpace(c.Query("after")); after != "" {
+			if sortSpec.Field != "imdb_id" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "after is only supported with sort=imdb_id", "code": "UNSUPPORTED_CURSOR_SORT"})
+				return
+			}
+			operator := "$gt"
+			if sortSpec.Descending {
+				operator = "$lt"
+			}
+			filter["imdb_id"] = bson.M{operator: after}
+		}
+
+		excludeComingSoon, ok := parseBoolQuery(c, "exclude_coming_soon", false)
+		if !ok {
+			return
+		}
+		if excludeComingSoon {
+			// $not 同时匹配没有 release_date 的电影
+			filter["release_date"] = bson.M{"$not": bson.M{"$gt": time.Now()}}
+		}
+		pipeline := append(mongo.Pipeline{{{Key: "$match", Value: filter}}}, movieSortStages(sortSpec)...)
+		// 多取一条判断是否还有下一页，多出的一条不返回
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit + 1}})
+
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+		if fields != nil {
+			pipeline = append(pipeline, bson.D{{Key: "$project", Value: movieFieldsProjection(fields)}})
+		}
+
+		truncateLength := 0
+		if value := c.Query("truncate_description"); value != "" {
+			length, err := strconv.Atoi(value)
+			if err != nil || length <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "truncate_description must be a positive integer"})
+				return
+			}
+			truncateLength = length
+		}
+
 		// 创建带超时的上下文，防止数据库操作超时
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client) // 电影集合
 
-		var movies []models.Movie
+		movies := []models.Movie{}
 
-		// 查询所有电影记录
-		cursor, err := movieCollection.Find(ctx, bson.M{})
-
-		if err != nil {
+		// 查询一页电影记录并解码到movies切片中，遇到瞬时错误自动重试
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &movies); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
 			return
 		}
-		defer cursor.Close(ctx)
+		nextCursor := ""
+		if int64(len(movies)) > limit {
+			movies = movies[:limit]
+			if sortSpec.Field == "imdb_id" {
+				nextCursor = movies[len(movies)-1].ImdbID
+			}
+		}
+		if truncateLength > 0 {
+			for i := range movies {
+				movies[i].Description = truncateText(movies[i].Description, truncateLength)
+			}
+		}
+		payload, err := moviesPayload(c, movies, fields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding movies"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"movies": payload, "next_cursor": nextCursor})
+	}
+}
+
+// GetComingSoonMovies 获取即将上线电影的处理器函数
+// 返回 release_date 晚于当前时间的电影，按上线日期升序分页；上线日期到达后电影自动从这里消失并出现在普通目录中
+func GetComingSoonMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		// 将查询结果解码到movies切片中
-		if err = cursor.All(ctx, &movies); err != nil {
+		filter := bson.M{"release_date": bson.M{"$gt": time.Now()}}
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "release_date", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+		movies := []models.Movie{}
+		if err := database.FindAll(ctx, movieCollection, filter, &movies, findOptions); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
 			return
 		}
-		// 返回成功响应和电影列表
-		c.JSON(http.StatusOK, movies)
+
+		respondMovies(c, movies, nil)
+	}
+}
+
+// GetNewMovieCount 获取某个时间之后新增电影数量的处理器函数
+// 返回 created_at 晚于 since（RFC3339 时间）的电影数量，供客户端显示"上次访问后新增 N 部电影"，
+// 只统计数量，不读取电影本身
+func GetNewMovieCount(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since, err := time.Parse(time.RFC3339, c.Query("since"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since is required and must be an RFC3339 timestamp"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		count, _, err := database.Count(ctx, movieCollection, bson.M{"created_at": bson.M{"$gt": since}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"count": count, "since": since})
 	}
 }
 
@@ -78,294 +226,1425 @@ func GetMovie(client *mongo.Client) gin.HandlerFunc {
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
 		// 根据IMDB ID查找电影
-		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieID}).Decode(&movie)
+		err := database.FindOne(ctx, movieCollection, bson.M{"imdb_id": movieID}, &movie)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
 			return
 		}
 		// 返回找到的电影信息
-		c.JSON(http.StatusOK, movie)
+		respondMovie(c, movie)
 	}
 }
 
 // AddMovie 添加新电影的处理器函数
 // 接收JSON格式的电影数据并存储到数据库中
+// 传入 ?upsert=true 时为"创建或更新"语义：imdb_id 已存在则整体替换该电影并返回 200，否则创建并返回 201；
+// 否则 imdb_id 已存在时返回 409
 func AddMovie(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 创建带超时的上下文
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
+		// upsert 会覆盖已有电影的全部字段，只允许管理员使用
+		upsert := c.Query("upsert") == "true"
+		if upsert && !requireAdmin(c) {
+			return
+		}
+
 		var movie models.Movie
 		// 将请求体中的JSON数据绑定到movie结构体
 		if err := c.ShouldBindJSON(&movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			respondBindError(c, err)
 			return
 		}
-		// 验证电影数据的有效性
-		if err := validate.Struct(movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		if err := prepareNewMovie(&movie); err != nil {
+			respondValidationError(c, err)
 			return
 		}
+
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
+		if upsert {
+			upsertMovie(ctx, c, client, movie)
+			return
+		}
+
+		// imdb_id 上的索引不是唯一索引（已有目录可能存在重复，见 /admin/movies/duplicates），插入前检查是否已存在
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movie.ImdbID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing movie"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Movie already exists", "code": "DUPLICATE_MOVIE", "imdb_id": movie.ImdbID})
+			return
+		}
+
 		// 将电影数据插入到数据库中
 		result, err := movieCollection.InsertOne(ctx, movie)
-
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Movie already exists", "code": "DUPLICATE_MOVIE", "imdb_id": movie.ImdbID})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding movie"})
 			return
 		}
+
+		invalidateRecommendations()
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionCreate, actorId, movie.ImdbID, nil)
+
 		// 返回创建成功的结果
 		c.JSON(http.StatusCreated, result)
 
 	}
 }
 
-// AdminReviewUpdate 管理员更新电影评论的处理器函数
-// 使用AI分析评论内容并自动分配排名等级
-func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
+// maxBulkMovies 单次批量添加的最大电影数
+const maxBulkMovies = 1000
+
+// findExistingImdbIds 用一次只投影 imdb_id 的 $in 查询找出目录中已存在的 imdb_id
+func findExistingImdbIds(ctx context.Context, movieCollection *mongo.Collection, imdbIds []string) (map[string]bool, error) {
+	existingIds := make(map[string]bool)
+	if len(imdbIds) == 0 {
+		return existingIds, nil
+	}
+
+	var existing []struct {
+		ImdbID string `bson:"imdb_id"`
+	}
+	err := database.FindAll(ctx, movieCollection, bson.M{"imdb_id": bson.M{"$in": imdbIds}}, &existing,
+		options.Find().SetProjection(bson.M{"_id": 0, "imdb_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for _, movie := range existing {
+		existingIds[movie.ImdbID] = true
+	}
+	return existingIds, nil
+}
+
+// CheckMoviesExist 批量检查 imdb_id 是否已在目录中的处理器函数（仅管理员）
+// 请求体为 {"imdb_ids": [...]}，最多 maxBulkMovies 个，返回按请求顺序去重后的 existing 和 missing 列表，
+// 用于导入外部数据前核对哪些电影已经存在，避免重复导入
+func CheckMoviesExist(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, err := utils.GetRoleFromContext(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found in context"})
+		if !requireAdmin(c) {
+			return
+		}
+
+		var req struct {
+			ImdbIDs []string `json:"imdb_ids" validate:"required,min=1,dive,required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
 			return
 		}
-		if role != "ADMIN" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
 			return
 		}
-		// 从URL参数获取电影ID
-		movieId := c.Param("imdb_id")
-		if movieId == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+		if len(req.ImdbIDs) > maxBulkMovies {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many imdb_ids, at most %d allowed per request", maxBulkMovies)})
 			return
 		}
 
-		// 定义请求和响应结构体
-		var req struct {
-			AdminReview string `json:"admin_review"`
+		seen := make(map[string]bool, len(req.ImdbIDs))
+		imdbIds := make([]string, 0, len(req.ImdbIDs))
+		for _, imdbId := range req.ImdbIDs {
+			imdbId = strings.TrimSpace(imdbId)
+			if imdbId == "" || seen[imdbId] {
+				continue
+			}
+			seen[imdbId] = true
+			imdbIds = append(imdbIds, imdbId)
 		}
-		var resp struct {
-			RankingName string `json:"ranking_name"`
-			AdminReview string `json:"admin_review"`
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		existingIds, err := findExistingImdbIds(ctx, movieCollection, imdbIds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing movies"})
+			return
 		}
 
-		// 绑定请求数据
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		existing, missing := []string{}, []string{}
+		for _, imdbId := range imdbIds {
+			if existingIds[imdbId] {
+				existing = append(existing, imdbId)
+			} else {
+				missing = append(missing, imdbId)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"existing": existing, "missing": missing})
+	}
+}
+
+// 批量添加中单条电影的处理结果
+const (
+	bulkStatusInserted  = "inserted"
+	bulkStatusDuplicate = "duplicate"
+	bulkStatusInvalid   = "invalid"
+	bulkStatusFailed    = "failed"
+)
+
+// bulkMovieResult 批量添加中单条电影的结果，Index 为其在请求数组中的下标
+type bulkMovieResult struct {
+	Index  int    `json:"index"`
+	ImdbID string `json:"imdb_id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkAddMovies 批量添加电影的处理器函数（仅管理员）
+// 请求体为电影数组，每条电影独立校验：无效的标记为 invalid，imdb_id 已存在或在请求中重复的标记为 duplicate，
+// 其余通过无序 InsertMany 一次写入。响应中的 results 与输入顺序一一对应，便于定位失败的行，
+// invalid_indices 列出未通过校验的下标，调用方只需修正这些电影后重新提交
+func BulkAddMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
 			return
 		}
 
-		// 使用AI分析评论并获取排名
-		sentiment, rankVal, err := GetReviewRanking(req.AdminReview, client, c)
-		if err != nil {
-			log.Printf("Error getting review ranking: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting review ranking", "details": err.Error()})
+		// 先按原始 JSON 解析，单条电影格式错误时只标记该条无效，不影响整批
+		var items []json.RawMessage
+		if err := c.ShouldBindJSON(&items); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No movies to add"})
+			return
+		}
+		if len(items) > maxBulkMovies {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many movies, at most %d allowed per request", maxBulkMovies)})
 			return
 		}
 
-		// 构建数据库更新操作
-		filter := bson.M{"imdb_id": movieId}
-		update := bson.M{
-			"$set": bson.M{
-				"admin_review": req.AdminReview,
-				"ranking": bson.M{
-					"ranking_value": rankVal,
-					"ranking_name":  sentiment,
-				},
-			},
+		results := make([]bulkMovieResult, len(items))
+		movies := make([]models.Movie, len(items))
+		for i, item := range items {
+			results[i] = bulkMovieResult{Index: i}
+			if err := json.Unmarshal(item, &movies[i]); err != nil {
+				results[i].Status = bulkStatusInvalid
+				results[i].Error = "Invalid movie JSON: " + err.Error()
+				continue
+			}
+			results[i].ImdbID = movies[i].ImdbID
+			if err := prepareNewMovie(&movies[i]); err != nil {
+				results[i].Status = bulkStatusInvalid
+				results[i].Error = validationMessage(err)
+			}
 		}
 
-		// 创建数据库操作上下文
-		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		// 执行数据库更新操作
-		result, err := movieCollection.UpdateOne(ctx, filter, update)
+		// 查询已存在的 imdb_id，同时标记请求内部的重复项（保留第一次出现的）
+		imdbIds := make([]string, 0, len(items))
+		for i := range results {
+			if results[i].Status == "" {
+				imdbIds = append(imdbIds, movies[i].ImdbID)
+			}
+		}
+		existingIds, err := findExistingImdbIds(ctx, movieCollection, imdbIds)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing movies"})
 			return
 		}
 
-		// 检查是否找到要更新的电影
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
-			return
+		firstIndex := make(map[string]int, len(imdbIds))
+		var docs []any
+		var docRows []int // docs[i] 对应的 results 下标
+		for i := range results {
+			if results[i].Status != "" {
+				continue
+			}
+			imdbId := movies[i].ImdbID
+			if existingIds[imdbId] {
+				results[i].Status = bulkStatusDuplicate
+				results[i].Error = "Movie already exists"
+				continue
+			}
+			if first, seen := firstIndex[imdbId]; seen {
+				results[i].Status = bulkStatusDuplicate
+				results[i].Error = fmt.Sprintf("Duplicate of index %d in this request", first)
+				continue
+			}
+			firstIndex[imdbId] = i
+			results[i].Status = bulkStatusInserted
+			docs = append(docs, movies[i])
+			docRows = append(docRows, i)
 		}
 
-		// 构建响应数据
-		resp.RankingName = sentiment
-		resp.AdminReview = req.AdminReview
+		if len(docs) > 0 {
+			_, err := movieCollection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+			var bulkErr mongo.BulkWriteException
+			if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+				for _, writeErr := range bulkErr.WriteErrors {
+					row := docRows[writeErr.Index]
+					if mongo.IsDuplicateKeyError(writeErr) {
+						results[row].Status = bulkStatusDuplicate
+						results[row].Error = "Movie already exists"
+					} else {
+						results[row].Status = bulkStatusFailed
+						results[row].Error = "Error adding movie"
+					}
+				}
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding movies"})
+				return
+			}
+		}
 
-		// 返回更新结果
-		c.JSON(http.StatusOK, resp)
+		invalidateRecommendations()
+		actorId, _ := utils.GetUserIdFromContext(c)
+		summary := map[string]int{
+			bulkStatusInserted:  0,
+			bulkStatusDuplicate: 0,
+			bulkStatusInvalid:   0,
+			bulkStatusFailed:    0,
+		}
+		invalidIndices := []int{}
+		for _, result := range results {
+			summary[result.Status]++
+			switch result.Status {
+			case bulkStatusInserted:
+				recordAudit(ctx, client, models.AuditActionCreate, actorId, result.ImdbID, bson.M{"bulk": true})
+			case bulkStatusInvalid:
+				invalidIndices = append(invalidIndices, result.Index)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total":           len(results),
+			"summary":         summary,
+			"invalid_indices": invalidIndices,
+			"results":         results,
+		})
 	}
 }
 
-// GetReviewRanking 使用AI分析评论内容并返回相应的排名等级
-// 参数: admin_review - 管理员评论内容
-// 返回: 排名名称, 排名数值, 错误信息
-func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context) (string, int, error) {
-	// 获取所有可用的排名等级
-	rankings, err := GetRankings(client, c)
+// prepareNewMovie 规范化并校验待创建的电影，设置时间戳并清零用户评分聚合字段
+// 返回的错误为 validator.ValidationErrors 或 *fieldError，可直接交给 respondValidationError
+func prepareNewMovie(movie *models.Movie) error {
+	// 规范化类型列表：去除空白、拒绝空名称、按名称（不区分大小写）去重
+	genres, err := normalizeGenres(movie.Genre)
 	if err != nil {
-		log.Printf("Error getting rankings: %v", err)
-		return "", 0, err
+		return &fieldError{Field: "genre", Err: err}
 	}
+	movie.Genre = genres
 
-	// 构建排名名称的逗号分隔字符串，用于AI提示
-	sentimentDelimited := ""
-	for _, ranking := range rankings {
-		if ranking.RankingValue != 999 { // 排除特殊值999
-			sentimentDelimited += ranking.RankingName + ","
-		}
-	}
-	sentimentDelimited = strings.Trim(sentimentDelimited, ",")
-
-	// 加载环境变量文件
-	err = godotenv.Load(".env")
+	tags, err := normalizeTags(movie.Tags)
 	if err != nil {
-		log.Println("Warning: Error loading .env file")
+		return &fieldError{Field: "tags", Err: err}
 	}
+	movie.Tags = tags
 
-	// 获取DeepSeek API密钥
-	deepseekApiKey := os.Getenv("DEEPSEEK_API_KEY")
-	if deepseekApiKey == "" {
-		log.Println("Error: DEEPSEEK_API_KEY is not set in .env file")
-		return "", 0, errors.New("DEEPSEEK_API_KEY is not set")
-	}
-
-	// 创建DeepSeek LLM实例（使用OpenAI兼容接口）
-	llm, err := openai.New(
-		openai.WithToken(deepseekApiKey),
-		openai.WithBaseURL("https://api.deepseek.com"),
-		openai.WithModel("deepseek-chat"),
-	)
+	cast, err := normalizeCast(movie.Cast)
 	if err != nil {
-		log.Printf("Error creating DeepSeek LLM: %v", err)
-		return "", 0, err
+		return &fieldError{Field: "cast", Err: err}
 	}
-
-	// 构建AI提示模板
-	base_prompt_template := os.Getenv("BASE_PROMPT_TEMPLATE")
-	base_prompt := strings.Replace(base_prompt_template, "{rankings}", sentimentDelimited, 1)
-
-	// 调用AI分析评论内容
-	response, err := llm.Call(context.Background(), base_prompt+admin_review)
+	movie.Cast = cast
+	directors, err := normalizeDirectors(movie.Directors)
 	if err != nil {
-		log.Printf("Error calling DeepSeek API: %v", err)
-		return "", 0, err
+		return &fieldError{Field: "directors", Err: err}
 	}
+	movie.Directors = directors
+	movie.CastKeys = castKeys(movie.Cast)
+	movie.DirectorKeys = personKeys(movie.Directors)
 
-	// 根据AI返回的排名名称查找对应的数值
-	rankVal := 0
-	for _, ranking := range rankings {
-		if ranking.RankingName == response {
-			rankVal = ranking.RankingValue
-			break
-		}
+	// 验证电影数据的有效性
+	if err := validate.Struct(movie); err != nil {
+		return err
 	}
+	if err := validateDescription(movie.Description); err != nil {
+		return &fieldError{Field: "description", Err: err}
+	}
+
+	movie.CreatedAt = time.Now()
+	movie.UpdatedAt = movie.CreatedAt
 
-	return response, rankVal, nil
+	// 锁定状态只能通过锁定接口修改
+	movie.Locked = false
+
+	// 聚合评分只能由评论接口维护
+	movie.UserRatingAvg = 0
+	movie.UserRatingCount = 0
+	movie.UserRatingSum = 0
+	return nil
 }
 
-// GetRankings 获取所有排名等级的辅助函数
-// 从数据库中查询所有可用的排名等级信息
-func GetRankings(client *mongo.Client, c *gin.Context) ([]models.Ranking, error) {
-	var rankings []models.Ranking
+// upsertMovie 按 imdb_id 创建或替换电影，用于幂等的数据初始化
+// 替换时保留原有的 _id、创建时间和用户评分聚合字段，这些字段不由客户端提供。
+// 整个过程是一次原子的管线更新：锁定状态在同一次写操作中判断，已锁定的电影所有字段保持原值并返回 423，
+// 不存在先检查再写入之间电影被锁定的窗口；也不会覆盖并发评论对聚合评分的修改
+func upsertMovie(ctx context.Context, c *gin.Context, client *mongo.Client, movie models.Movie) {
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+	// imdb_id 索引不是唯一索引，不能把锁定条件放进 upsert 的过滤条件，否则会为锁定的电影插入一份副本
+	filter := bson.M{"imdb_id": movie.ImdbID}
 
-	// 创建带超时的上下文
-	var ctx, cancel = context.WithTimeout(c, 100*time.Second)
-	defer cancel()
-	var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+	replace := bson.M{
+		"title":        movie.Title,
+		"description":  movie.Description,
+		"poster_path":  movie.PosterPath,
+		"youtube_id":   movie.YouTubeID,
+		"genre":        movie.Genre,
+		"admin_review": movie.AdminReview,
+		"ranking":      movie.Ranking,
+		"updated_at":   movie.UpdatedAt,
+	}
+	// 整体替换语义：未提供标签、演职员或上线日期时移除原有值
+	var removed []string
+	if len(movie.Tags) > 0 {
+		replace["tags"] = movie.Tags
+	} else {
+		removed = append(removed, "tags")
+	}
+	if len(movie.Cast) > 0 {
+		replace["cast"] = movie.Cast
+		replace["cast_keys"] = movie.CastKeys
+	} else {
+		removed = append(removed, "cast", "cast_keys")
+	}
+	if len(movie.Directors) > 0 {
+		replace["directors"] = movie.Directors
+		replace["director_keys"] = movie.DirectorKeys
+	} else {
+		removed = append(removed, "directors", "director_keys")
+	}
+	if movie.ReleaseDate != nil {
+		replace["release_date"] = movie.ReleaseDate
+	} else {
+		removed = append(removed, "release_date")
+	}
 
-	// 查询所有排名记录
-	cursor, err := rankingCollection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, err
+	// 管线中的表达式都基于更新前的文档求值：已锁定时每个字段取原值（"$field"），否则取新值或移除。
+	// 客户端提供的值用 $literal 包装，避免以 $ 开头的字符串被当作字段路径
+	locked := bson.M{"$eq": bson.A{"$locked", true}}
+	unlessLocked := func(field string, value any) bson.M {
+		return bson.M{"$cond": bson.A{locked, "$" + field, value}}
+	}
+	movieId := bson.NewObjectID()
+	set := bson.M{
+		// 保留字段只在缺失（即新建）时初始化
+		"_id":               bson.M{"$ifNull": bson.A{"$_id", movieId}},
+		"created_at":        bson.M{"$ifNull": bson.A{"$created_at", movie.CreatedAt}},
+		"user_rating_avg":   bson.M{"$ifNull": bson.A{"$user_rating_avg", 0}},
+		"user_rating_count": bson.M{"$ifNull": bson.A{"$user_rating_count", 0}},
+		"user_rating_sum":   bson.M{"$ifNull": bson.A{"$user_rating_sum", 0}},
+	}
+	for field, value := range replace {
+		set[field] = unlessLocked(field, bson.M{"$literal": value})
+	}
+	for _, field := range removed {
+		set[field] = unlessLocked(field, "$$REMOVE")
 	}
-	defer cursor.Close(ctx)
 
-	// 将查询结果解码到rankings切片中
-	if err := cursor.All(ctx, &rankings); err != nil {
-		return nil, err
+	// 返回更新前的锁定状态：没有文档说明是新建，已锁定说明本次写入没有修改任何字段
+	var previous models.Movie
+	err := movieCollection.FindOneAndUpdate(ctx, filter,
+		mongo.Pipeline{{{Key: "$set", Value: set}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before).SetProjection(bson.M{"locked": 1}),
+	).Decode(&previous)
+	inserted := errors.Is(err, mongo.ErrNoDocuments)
+	if err != nil && !inserted {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error upserting movie"})
+		return
+	}
+	if previous.Locked {
+		respondMovieLocked(c, movie.ImdbID)
+		return
 	}
 
-	return rankings, nil
+	// 响应保持 UpdateResult 的结构
+	result := &mongo.UpdateResult{Acknowledged: true, MatchedCount: 1, ModifiedCount: 1}
+	if inserted {
+		result = &mongo.UpdateResult{Acknowledged: true, UpsertedCount: 1, UpsertedID: movieId}
+	}
+
+	// 新建返回 201，更新已有电影返回 200
+	status, action := http.StatusOK, models.AuditActionUpdate
+	if result.UpsertedCount > 0 {
+		status, action = http.StatusCreated, models.AuditActionCreate
+	}
+
+	invalidateRecommendations()
+	actorId, _ := utils.GetUserIdFromContext(c)
+	recordAudit(ctx, client, action, actorId, movie.ImdbID, bson.M{"upsert": true})
+
+	c.JSON(status, result)
 }
 
-// GetRecommendedMovies 获取用户推荐电影的处理器函数
-// 根据用户喜欢的电影类型，返回评分最高的推荐电影列表
-func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
+// UpdateMovie 部分更新电影的处理器函数（仅管理员）
+// 只修改请求体中提供的字段，返回更新后的完整电影
+func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从上下文中获取用户ID
-		userId, err := utils.GetUserIdFromContext(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+		if !requireAdmin(c) {
 			return
 		}
 
-		// 获取用户喜欢的电影类型列表
-		favourite_genres, err := GetUserFavouriteGenres(userId, client, c)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting favourite genres"})
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
 			return
 		}
 
-		// 加载环境变量文件
-		err = godotenv.Load(".env")
-		if err != nil {
-			log.Println("Warning: Error loading .env file")
-		}
-
-		// 从环境变量获取推荐电影数量限制，默认为5部
-		var recommendedMoviesLimitVal int64 = 5
-		recommendedMoviesLimitStr := os.Getenv("RECOMMENDED_MOVIES_LIMIT")
-		if recommendedMoviesLimitStr != "" {
-			recommendedMoviesLimitVal, _ = strconv.ParseInt(recommendedMoviesLimitStr, 10, 64)
+		var req models.MovieUpdate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
 		}
-
-		// 设置查询选项：按排名值升序排序（值越小排名越高），限制返回数量
-		findOptions := options.Find()
-		findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
-		findOptions.SetLimit(recommendedMoviesLimitVal)
-
-		// 构建过滤条件：电影类型在用户喜欢的类型列表中
-		filter := bson.M{"genre.genre_name": bson.M{"$in": favourite_genres}}
-
-		// 创建数据库操作上下文
-		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
-		defer cancel()
-		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
-
-		// 执行数据库查询
-		cursor, err := movieCollection.Find(ctx, filter, findOptions)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+		// 允许原样回传 imdb_id，便于客户端提交完整的电影对象
+		if req.ImdbID != nil && *req.ImdbID != movieId {
+			respondFieldError(c, "imdb_id", errors.New("imdb_id cannot be changed"))
 			return
 		}
-		defer cursor.Close(ctx)
-
-		// 将查询结果解码到推荐电影列表中
-		var recommendedMovies []models.Movie
-		if err := cursor.All(ctx, &recommendedMovies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding recommended movies"})
+		if req.Genre != nil {
+			genres, err := normalizeGenres(*req.Genre)
+			if err != nil {
+				respondFieldError(c, "genre", err)
+				return
+			}
+			req.Genre = &genres
+		}
+		if req.Cast != nil {
+			cast, err := normalizeCast(*req.Cast)
+			if err != nil {
+				respondFieldError(c, "cast", err)
+				return
+			}
+			req.Cast = &cast
+		}
+		if req.Directors != nil {
+			directors, err := normalizeDirectors(*req.Directors)
+			if err != nil {
+				respondFieldError(c, "directors", err)
+				return
+			}
+			req.Directors = &directors
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
 			return
 		}
 
-		// 返回推荐电影列表
-		c.JSON(http.StatusOK, recommendedMovies)
-
-	}
+		set := bson.M{}
+		if req.Title != nil {
+			set["title"] = *req.Title
+		}
+		if req.Description != nil {
+			if err := validateDescription(*req.Description); err != nil {
+				respondFieldError(c, "description", err)
+				return
+			}
+			set["description"] = *req.Description
+		}
+		if req.PosterPath != nil {
+			set["poster_path"] = *req.PosterPath
+		}
+		if req.YouTubeID != nil {
+			set["youtube_id"] = *req.YouTubeID
+		}
+		if req.Genre != nil {
+			set["genre"] = *req.Genre
+		}
+		if req.Cast != nil {
+			set["cast"] = *req.Cast
+		}
+		if req.Directors != nil {
+			set["directors"] = *req.Directors
+		}
+		if req.ReleaseDate != nil {
+			set["release_date"] = *req.ReleaseDate
+		}
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			return
+		}
+
+		changedFields := make([]string, 0, len(set))
+		for field := range set {
+			changedFields = append(changedFields, field)
+		}
+		sort.Strings(changedFields)
+		// 规范化姓名随演职员一起更新，不计入审计的修改字段
+		if req.Cast != nil {
+			set["cast_keys"] = castKeys(*req.Cast)
+		}
+		if req.Directors != nil {
+			set["director_keys"] = personKeys(*req.Directors)
+		}
+		set["updated_at"] = time.Now()
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movie models.Movie
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		err := movieCollection.FindOneAndUpdate(ctx,
+			unlockedMovieFilter(movieId),
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&movie)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			respondMovieNotUpdated(ctx, c, movieCollection, movieId)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
+
+		invalidateRecommendations()
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionUpdate, actorId, movieId, bson.M{"fields": changedFields})
+
+		c.JSON(http.StatusOK, movie)
+	}
+}
+
+// movieReferenceCollections 以 imdb_id 引用电影的集合，删除电影时一并清理
+var movieReferenceCollections = []string{"reviews", "progress", "failed_rankings"}
+
+// DeleteMovie 删除电影的处理器函数（仅管理员）
+// 删除与锁定检查在同一次操作中完成，锁定的电影返回 423，需要先解锁；电影不存在时返回 404
+// 电影删除后清理引用该电影的评论、观看进度和待重试的排名，清理失败只记录日志，不影响删除结果
+func DeleteMovie(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		// imdb_id 不是唯一索引时可能存在重复文档，一并删除，避免残留的副本继续被 GetMovie 返回
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		result, err := movieCollection.DeleteMany(ctx, unlockedMovieFilter(movieId))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting movie"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			respondMovieNotUpdated(ctx, c, movieCollection, movieId)
+			return
+		}
+
+		invalidateRecommendations()
+		removed := gin.H{}
+		for _, name := range movieReferenceCollections {
+			refResult, err := database.OpenCollection(name, client).DeleteMany(ctx, bson.M{"imdb_id": movieId})
+			if err != nil {
+				logger.Error("error removing movie references", "imdb_id", movieId, "collection", name, "error", err)
+				continue
+			}
+			removed[name] = refResult.DeletedCount
+		}
+
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionDelete, actorId, movieId, bson.M{
+			"deleted_count":      result.DeletedCount,
+			"removed_references": removed,
+		})
+
+		c.JSON(http.StatusOK, gin.H{"imdb_id": movieId, "deleted_count": result.DeletedCount, "removed_references": removed})
+	}
+}
+
+// maxDescriptionLength 返回电影描述允许的最大字符数
+// 优先读取环境变量 MAX_DESCRIPTION_LENGTH，默认 5000
+func maxDescriptionLength() int {
+	if value, err := strconv.Atoi(os.Getenv("MAX_DESCRIPTION_LENGTH")); err == nil && value > 0 {
+		return value
+	}
+	return 5000
+}
+
+// validateDescription 检查描述长度（按字符计算）是否超出上限
+func validateDescription(description string) error {
+	if limit := maxDescriptionLength(); utf8.RuneCountInString(description) > limit {
+		return fmt.Errorf("description must be at most %d characters", limit)
+	}
+	return nil
+}
+
+// truncateText 将文本截断为最多 n 个字符（按字符而非字节计算），截断时追加省略号
+func truncateText(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…"
+}
+
+// normalizeGenres 规范化电影的类型列表
+// 去除类型名称首尾空白，拒绝空名称，并按名称（不区分大小写）去重，保留首次出现的项
+// 重复的类型会导致 $unwind 统计偏大以及前端显示重复标签
+func normalizeGenres(genres []models.Genre) ([]models.Genre, error) {
+	seen := make(map[string]bool, len(genres))
+	normalized := make([]models.Genre, 0, len(genres))
+	for _, genre := range genres {
+		genre.GenreName = strings.TrimSpace(genre.GenreName)
+		if genre.GenreName == "" {
+			return nil, errors.New("genre_name must not be empty")
+		}
+		key := strings.ToLower(genre.GenreName)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, genre)
+	}
+	return normalized, nil
+}
+
+// AdminReviewUpdate 管理员更新电影评论的处理器函数
+// 使用AI分析评论内容并自动分配排名等级
+// LLM_DISABLED=true 时不调用 AI，直接分配默认排名（见 fallbackRanking），响应中 ai_generated 为 false
+// AI 调用失败时评论不会保存，而是记录到 failed_rankings 队列，由 RetryFailedRankings 重新处理
+func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		// 从URL参数获取电影ID
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		// 定义请求和响应结构体
+		var req struct {
+			AdminReview string `json:"admin_review"`
+		}
+		// 响应中包含完整的 ranking 子文档，客户端无需再请求 GetMovie 即可渲染排名徽章
+		var resp struct {
+			RankingName  string         `json:"ranking_name"`
+			RankingValue int            `json:"ranking_value"`
+			AdminReview  string         `json:"admin_review"`
+			Ranking      models.Ranking `json:"ranking"`
+			UpdatedAt    time.Time      `json:"updated_at"`
+			AIGenerated  bool           `json:"ai_generated"`
+		}
+
+		// 绑定请求数据
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		// 创建数据库操作上下文
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		// 调用 AI 之前先检查锁定状态，避免为无法写入的电影消耗 AI 调用
+		if !checkMovieUnlocked(ctx, c, movieCollection, movieId) {
+			return
+		}
+
+		// 使用AI分析评论并获取排名，未启用 AI 时使用默认排名
+		var sentiment string
+		var rankVal int
+		var err error
+		aiGenerated := !llmDisabled()
+		if aiGenerated {
+			sentiment, rankVal, err = GetReviewRanking(req.AdminReview, client, c)
+		} else {
+			var fallback models.Ranking
+			fallback, err = fallbackRanking(client, c)
+			sentiment, rankVal = fallback.RankingName, fallback.RankingValue
+		}
+		if errors.Is(err, ErrNoRankings) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No rankings configured, please seed the rankings collection before updating reviews"})
+			return
+		}
+		if err != nil {
+			logger.Error("error getting review ranking", "imdb_id", movieId, "error", err)
+			// 记录到失败队列，管理员可以通过 POST /admin/rankings/retry-failed 重新处理
+			actorId, _ := utils.GetUserIdFromContext(c)
+			recordFailedRanking(ctx, client, movieId, req.AdminReview, actorId, err)
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, ErrLLMUnavailable):
+				status = http.StatusServiceUnavailable
+			case errors.Is(err, ErrUnrecognizedRanking):
+				status = http.StatusBadGateway
+			}
+			c.JSON(status, gin.H{"error": "Error getting review ranking", "details": err.Error(), "queued_for_retry": true})
+			return
+		}
+
+		// 构建数据库更新操作
+		ranking := models.Ranking{RankingValue: rankVal, RankingName: sentiment}
+		updatedAt := time.Now()
+		filter := unlockedMovieFilter(movieId)
+		update := bson.M{
+			"$set": bson.M{
+				"admin_review": req.AdminReview,
+				"ranking":      ranking,
+				"updated_at":   updatedAt,
+			},
+		}
+
+		// 执行数据库更新操作
+		result, err := movieCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
+
+		// 检查是否找到要更新的电影（不存在或在调用 AI 期间被锁定）
+		if result.MatchedCount == 0 {
+			respondMovieNotUpdated(ctx, c, movieCollection, movieId)
+			return
+		}
+
+		invalidateRecommendations()
+		clearFailedRanking(ctx, client, movieId)
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionRank, actorId, movieId, bson.M{
+			"ranking_name":  sentiment,
+			"ranking_value": rankVal,
+			"ai_generated":  aiGenerated,
+		})
+
+		// 构建响应数据
+		resp.RankingName = sentiment
+		resp.RankingValue = rankVal
+		resp.AdminReview = req.AdminReview
+		resp.Ranking = ranking
+		resp.UpdatedAt = updatedAt
+		resp.AIGenerated = aiGenerated
+
+		// 返回更新结果
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// SetMovieRanking 管理员手动设置电影排名的处理器函数
+// 请求体只包含 ranking_name，在排名集合中查找对应的 ranking_value 后直接写入电影，完全绕过 AI，
+// 用于管理员不同意 AI 判断的情况；返回更新后的完整电影
+func SetMovieRanking(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var req struct {
+			RankingName string `json:"ranking_name" validate:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		req.RankingName = strings.TrimSpace(req.RankingName)
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+
+		rankings, err := GetRankings(client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+			return
+		}
+		if len(rankings) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No rankings configured, please seed the rankings collection before setting rankings"})
+			return
+		}
+		var ranking models.Ranking
+		found := false
+		for _, candidate := range rankings {
+			if candidate.RankingName == req.RankingName {
+				ranking, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			respondFieldError(c, "ranking_name", fmt.Errorf("unknown ranking %q", req.RankingName))
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movie models.Movie
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		err = movieCollection.FindOneAndUpdate(ctx,
+			unlockedMovieFilter(movieId),
+			bson.M{"$set": bson.M{"ranking": ranking, "updated_at": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&movie)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			respondMovieNotUpdated(ctx, c, movieCollection, movieId)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
+
+		invalidateRecommendations()
+		clearFailedRanking(ctx, client, movieId)
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionRank, actorId, movieId, bson.M{
+			"ranking_name":  ranking.RankingName,
+			"ranking_value": ranking.RankingValue,
+			"ai_generated":  false,
+			"manual":        true,
+		})
+
+		c.JSON(http.StatusOK, movie)
+	}
+}
+
+// GetReviewRanking 使用AI分析评论内容并返回相应的排名等级
+// 参数: admin_review - 管理员评论内容
+// 返回: 排名名称, 排名数值, 错误信息
+func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context) (string, int, error) {
+	// 获取所有可用的排名等级
+	rankings, err := GetRankings(client, c)
+	if err != nil {
+		logger.Error("error getting rankings", "error", err)
+		return "", 0, err
+	}
+
+	// 可供 AI 选择的排名等级名称
+	unrankedValue := unrankedRankingValue()
+	rankingNames := []string{}
+	for _, ranking := range rankings {
+		if ranking.RankingValue != unrankedValue { // 排除"未排名"哨兵等级
+			rankingNames = append(rankingNames, ranking.RankingName)
+		}
+	}
+
+	// 没有可选的排名等级时提示词没有意义，直接返回错误，避免保存模型凭空编造的排名
+	if len(rankingNames) == 0 {
+		return "", 0, ErrNoRankings
+	}
+
+	// 并发提交的相同评论（忽略空白差异）共享同一次 AI 调用和结果，调用失败时所有等待者都收到同一个错误
+	key := strings.Join(rankingNames, ",") + "\x00" + strings.Join(strings.Fields(admin_review), " ")
+	result, err, _ := reviewRankingGroup.Do(key, func() (any, error) {
+		return rankReview(admin_review, rankingNames)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	response := result.(string)
+
+	ranking, err := rankingFromResponse(response, rankings, unrankedValue)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return ranking.RankingName, ranking.RankingValue, nil
+}
+
+// rankingFromResponse 根据AI返回的排名名称查找对应的等级，找不到时返回 ErrUnrecognizedRanking，避免以 0 作为排名值保存
+func rankingFromResponse(response string, rankings []models.Ranking, unrankedValue int) (models.Ranking, error) {
+	ranking, ok := matchRanking(response, rankings, unrankedValue)
+	if !ok {
+		logger.Error("unrecognized ranking in AI response", "response", response)
+		return models.Ranking{}, fmt.Errorf("%w: %q", ErrUnrecognizedRanking, response)
+	}
+	return ranking, nil
+}
+
+// normalizeRankingText 规范化排名名称以便比较：转为小写，去掉引号和标点，合并空白
+func normalizeRankingText(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// matchRanking 将 AI 的回复对应到排名等级（不包括"未排名"哨兵等级）
+// 模型经常在名称外附加空白、引号、句号，甚至返回 "The ranking is Excellent." 这样的完整句子：
+// 规范化后完全相等的优先，否则取回复中作为完整单词出现的最长等级名称，使 "Not Good" 不会被识别为 "Good"
+func matchRanking(response string, rankings []models.Ranking, unrankedValue int) (models.Ranking, bool) {
+	normalized := normalizeRankingText(response)
+	var best models.Ranking
+	bestLength := 0
+	for _, ranking := range rankings {
+		if ranking.RankingValue == unrankedValue {
+			continue
+		}
+		name := normalizeRankingText(ranking.RankingName)
+		if name == "" {
+			continue
+		}
+		if name == normalized {
+			return ranking, true
+		}
+		if strings.Contains(" "+normalized+" ", " "+name+" ") && len(name) > bestLength {
+			best, bestLength = ranking, len(name)
+		}
+	}
+	return best, bestLength > 0
+}
+
+// reviewRankingGroup 对并发的相同评论排名请求去重，避免重复调用 AI
+var reviewRankingGroup singleflight.Group
+
+// rankReview 调用当前的 ReviewRanker，返回模型给出的排名名称
+// 使用 context.Background()，共享调用不会因为某一个请求被取消而让其他等待者一起失败；
+// 每次调用的超时和瞬时错误的重试由 callLLMWithRetry 控制
+func rankReview(review string, rankingNames []string) (string, error) {
+	ranker := currentReviewRanker()
+	response, err := callLLMWithRetry(context.Background(), func(ctx context.Context) (string, error) {
+		return ranker.Rank(ctx, review, rankingNames)
+	})
+	if err != nil {
+		logger.Error("error calling LLM", "error", err)
+		return "", err
+	}
+	return response, nil
+}
+
+// llmDisabled 判断是否通过环境变量 LLM_DISABLED 关闭了 AI 排名（用于 CI、本地开发等没有 API 密钥的环境），
+// 或者关闭了 llm 功能开关
+func llmDisabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv("LLM_DISABLED"))
+	return disabled || !features.Enabled(features.LLM)
+}
+
+// fallbackRanking 返回不调用 AI 时分配的默认排名
+// 优先使用环境变量 LLM_FALLBACK_RANKING 指定名称的排名，否则使用"未排名"哨兵等级；
+// 排名集合中找不到对应等级时返回 ErrNoRankings
+func fallbackRanking(client *mongo.Client, c *gin.Context) (models.Ranking, error) {
+	rankings, err := GetRankings(client, c)
+	if err != nil {
+		return models.Ranking{}, err
+	}
+
+	name := strings.TrimSpace(os.Getenv("LLM_FALLBACK_RANKING"))
+	unrankedValue := unrankedRankingValue()
+	for _, ranking := range rankings {
+		if (name != "" && ranking.RankingName == name) || (name == "" && ranking.RankingValue == unrankedValue) {
+			return ranking, nil
+		}
+	}
+	return models.Ranking{}, ErrNoRankings
+}
+
+// unrankedRankingValue 返回表示"未排名"的哨兵排名值
+// 优先读取环境变量 UNRANKED_RANKING_VALUE，否则使用 models.DefaultUnrankedValue
+func unrankedRankingValue() int {
+	if value, err := strconv.Atoi(os.Getenv("UNRANKED_RANKING_VALUE")); err == nil {
+		return value
+	}
+	return models.DefaultUnrankedValue
+}
+
+// GetRankings 获取所有排名等级的辅助函数
+// 从数据库中查询所有可用的排名等级信息
+func GetRankings(client *mongo.Client, c *gin.Context) ([]models.Ranking, error) {
+	var rankings []models.Ranking
+
+	// 创建带超时的上下文
+	var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+	var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+	// 查询所有排名记录并解码到rankings切片中
+	if err := database.FindAll(ctx, rankingCollection, bson.M{}, &rankings); err != nil {
+		return nil, err
+	}
+
+	return rankings, nil
+}
+
+// cachedRecommendations 缓存的推荐结果及其计算时间
+type cachedRecommendations struct {
+	Movies      []models.Movie
+	GeneratedAt time.Time
+}
+
+// recommendationsGeneration 推荐缓存的代数，缓存键包含代数，递增后之前缓存的结果不再命中
+var recommendationsGeneration atomic.Uint64
+
+// invalidateRecommendations 使所有已缓存的推荐结果失效
+// 在修改电影的接口（新增、编辑、删除、排名、锁定、标签、导入等）成功写入后调用；
+// 每条评论带来的评分聚合变化不触发失效，推荐结果中的用户评分最多滞后一个缓存周期
+func invalidateRecommendations() {
+	recommendationsGeneration.Add(1)
+}
+
+// GetRecommendedMovies 获取用户推荐电影的处理器函数
+// 根据用户喜欢的电影类型，返回评分最高的推荐电影列表
+// 数量默认为 RECOMMENDED_MOVIES_LIMIT（默认 5），可通过 limit 参数覆盖，最多 50 部
+// 支持与 GetMovies 相同的 fields 参数裁剪返回字段，imdb_id 和 ranking 始终返回
+// 未排名的电影默认排在最后，传入 include_unranked=false 时完全排除
+// 响应为 {"movies": [...], "generated_at": ...}，generated_at 为结果实际计算的时间（命中缓存时为原始计算时间），
+// 同时通过 X-Generated-At 响应头返回，X-Cache 标明是否命中
+// 结果缓存 RECOMMENDATIONS_CACHE_TTL_SECONDS 秒（默认 300 秒），电影被修改后缓存立即失效（见 invalidateRecommendations）；
+// 传入 refresh=true 跳过缓存重新计算
+func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
+	ttl := 300 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("RECOMMENDATIONS_CACHE_TTL_SECONDS")); err == nil && value > 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	recommendationCache := cache.New[cachedRecommendations](ttl, 1000)
+
+	return func(c *gin.Context) {
+		// 从上下文中获取用户ID
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+		includeUnranked, ok := parseIncludeUnranked(c)
+		if !ok {
+			return
+		}
+		refresh, ok := parseBoolQuery(c, "refresh", false)
+		if !ok {
+			return
+		}
+		limit, ok := parseRecommendedLimit(c)
+		if !ok {
+			return
+		}
+
+		// 获取用户喜欢的电影类型列表
+		favourite_genres, err := GetUserFavouriteGenres(userId, client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting favourite genres"})
+			return
+		}
+
+		// 缓存键由喜爱类型和查询参数组成而不是用户 ID，类型修改后自然不会命中旧结果，相同偏好的用户共享缓存
+		// 代数在查询前读取：查询期间电影被修改时，结果写入旧代数的键，不会被之后的请求命中
+		cacheKey := fmt.Sprintf("%d|%v|%d|%s|%t", recommendationsGeneration.Load(), favourite_genres, limit, strings.Join(fields, ","), includeUnranked)
+		if !refresh {
+			if cached, ok := recommendationCache.Get(cacheKey); ok {
+				c.Header("X-Cache", "HIT")
+				respondRecommendations(c, cached, fields)
+				return
+			}
+		}
+
+		// 创建数据库操作上下文
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		// 按用户喜欢的类型查询推荐电影
+		recommendedMovies, err := findRecommendedMovies(ctx, client, favourite_genres, limit, fields, includeUnranked)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+			return
+		}
+
+		recommendations := cachedRecommendations{Movies: recommendedMovies, GeneratedAt: time.Now()}
+		recommendationCache.Set(cacheKey, recommendations)
+
+		// 返回推荐电影列表
+		c.Header("X-Cache", "MISS")
+		respondRecommendations(c, recommendations, fields)
+
+	}
+}
+
+// respondRecommendations 写入推荐结果的响应，电影列表按 respondMovies 的规则输出
+func respondRecommendations(c *gin.Context, recommendations cachedRecommendations, fields []string) {
+	payload, err := moviesPayload(c, recommendations.Movies, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding movies"})
+		return
+	}
+	c.Header("X-Generated-At", recommendations.GeneratedAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{"movies": payload, "generated_at": recommendations.GeneratedAt})
+}
+
+// GetRecommendationsPreview 根据查询参数中给定的类型预览推荐电影的处理器函数
+// 无需登录，例如 /recommendations/preview?genres=Action,Comedy
+// 与 GetRecommendedMovies 使用相同的排名排序规则和 include_unranked 参数，供营销页面展示"如果你喜欢 X，我们会推荐"
+func GetRecommendationsPreview(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 解析逗号分隔的类型列表，忽略空项；纯数字视为 genre_id，其余视为 genre_name
+		var genres []models.Genre
+		for _, genre := range strings.Split(c.Query("genres"), ",") {
+			if genre = strings.TrimSpace(genre); genre == "" {
+				continue
+			}
+			if id, err := strconv.Atoi(genre); err == nil {
+				genres = append(genres, models.Genre{GenreID: id})
+			} else {
+				genres = append(genres, models.Genre{GenreName: genre})
+			}
+		}
+		if len(genres) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one genre is required"})
+			return
+		}
+		if len(genres) > maxPreviewGenres {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many genres", "max": maxPreviewGenres})
+			return
+		}
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+		includeUnranked, ok := parseIncludeUnranked(c)
+		if !ok {
+			return
+		}
+		limit, ok := parseRecommendedLimit(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		recommendedMovies, err := findRecommendedMovies(ctx, client, genres, limit, fields, includeUnranked)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+			return
+		}
+
+		respondMovies(c, recommendedMovies, fields)
+	}
+}
+
+// maxPreviewGenres 推荐预览接口允许传入的最大类型数量
+const maxPreviewGenres = 20
+
+// 按类型分组推荐接口的限制，保证响应体大小有上限
+const (
+	maxByGenreGenres = 10 // 最多返回的类型数量，超出的喜爱类型被忽略
+	maxByGenreLimit  = 20 // 每个类型最多返回的电影数量
+)
+
+// GetRecommendationsByGenre 按用户喜爱的类型分组返回推荐电影的处理器函数
+// 对每个喜爱的类型（最多 maxByGenreGenres 个）返回排名最高的 limit 部电影（默认 5，最大 maxByGenreLimit），
+// 结果是以类型名称为键的对象，通过一次 $facet 聚合完成，替代首页逐个类型请求；
+// 默认按排名排序，也可以传入 sortableMovieFields 中的字段作为 sort
+func GetRecommendationsByGenre(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		limit, err := strconv.ParseInt(c.DefaultQuery("limit", "5"), 10, 64)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if limit > maxByGenreLimit {
+			limit = maxByGenreLimit
+		}
+		sortSpec, ok := parseMovieSort(c, "ranking:asc")
+		if !ok {
+			return
+		}
+
+		genres, err := GetUserFavouriteGenres(userId, client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting favourite genres"})
+			return
+		}
+		if len(genres) > maxByGenreGenres {
+			genres = genres[:maxByGenreGenres]
+		}
+		grouped := make(map[string][]models.Movie, len(genres))
+		if len(genres) == 0 {
+			c.JSON(http.StatusOK, grouped)
+			return
+		}
+
+		// $facet 的键不能包含 "." 等字符，这里用序号作为键，解码后再换回类型名称
+		facets := bson.M{}
+		for i, genre := range genres {
+			facets[strconv.Itoa(i)] = append(
+				mongo.Pipeline{{{Key: "$match", Value: genreMatchFilter([]models.Genre{genre})}}},
+				rankedMoviesStages(sortSpec, limit, nil)...,
+			)
+		}
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: genreMatchFilter(genres)}},
+			{{Key: "$facet", Value: facets}},
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var results []map[string][]models.Movie
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &results); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+			return
+		}
+
+		for i, genre := range genres {
+			name := genre.GenreName
+			if name == "" {
+				name = strconv.Itoa(genre.GenreID)
+			}
+			movies := []models.Movie{}
+			if len(results) > 0 && results[0][strconv.Itoa(i)] != nil {
+				movies = results[0][strconv.Itoa(i)]
+			}
+			grouped[name] = movies
+		}
+
+		c.JSON(http.StatusOK, grouped)
+	}
+}
+
+// rankingSortKeyStage 返回计算 ranking_sort_key 的 $addFields 阶段
+// 排序键等于排名值，"未排名"哨兵值以及缺失或为 null 的排名都映射为最大值，使其升序排序时排在最后
+// （否则缺失的排名值会被当作 null 排在最前面）
+func rankingSortKeyStage() bson.D {
+	unrankedValue := unrankedRankingValue()
+	return bson.D{{Key: "$addFields", Value: bson.M{"ranking_sort_key": bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$ranking.ranking_value", unrankedValue}}, unrankedValue}},
+		math.MaxInt32,
+		"$ranking.ranking_value",
+	}}}}}
+}
+
+// parseIncludeUnranked 解析 include_unranked 查询参数，默认为 true
+// 参数不是合法的布尔值时写入 400 响应并返回 ok=false
+func parseIncludeUnranked(c *gin.Context) (includeUnranked bool, ok bool) {
+	return parseBoolQuery(c, "include_unranked", true)
+}
+
+// parseBoolQuery 解析布尔类型的查询参数，未传入时返回 defaultValue
+// 参数不是合法的布尔值时写入 400 响应并返回 ok=false
+func parseBoolQuery(c *gin.Context, name string, defaultValue bool) (value bool, ok bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be true or false"})
+		return false, false
+	}
+	return value, true
+}
+
+// maxRecommendedMoviesLimit 单次推荐最多返回的电影数
+const maxRecommendedMoviesLimit = 50
+
+// recommendedMoviesLimit 从环境变量 RECOMMENDED_MOVIES_LIMIT 获取默认的推荐电影数量，默认为5部
+// 环境变量在启动时从 .env 加载，这里只读取进程环境
+func recommendedMoviesLimit() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("RECOMMENDED_MOVIES_LIMIT"), 10, 64); err == nil && value > 0 {
+		return min(value, maxRecommendedMoviesLimit)
+	}
+	return 5
+}
+
+// parseRecommendedLimit 解析推荐接口的 limit 查询参数，未传入时使用 recommendedMoviesLimit，
+// 超过 maxRecommendedMoviesLimit 时按上限处理，不是正整数时写入 400 响应并返回 ok=false
+// 侧边栏等小组件和完整推荐页可以各自请求不同的数量
+func parseRecommendedLimit(c *gin.Context) (int64, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return recommendedMoviesLimit(), true
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return 0, false
+	}
+	return min(limit, maxRecommendedMoviesLimit), true
+}
+
+// genreMatchFilter 构建匹配给定类型的电影过滤条件
+// 类型可以通过 genre_id 或 genre_name 标识，两者任一匹配即可，
+// 这样即使用户文档和电影文档中的类型表示方式不一致（只有 id 或只有名称），推荐也不会静默变为空
+func genreMatchFilter(genres []models.Genre) bson.M {
+	names := []string{}
+	ids := []int{}
+	for _, genre := range genres {
+		if genre.GenreName != "" {
+			names = append(names, genre.GenreName)
+		}
+		if genre.GenreID != 0 {
+			ids = append(ids, genre.GenreID)
+		}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"genre.genre_name": bson.M{"$in": names}},
+		bson.M{"genre.genre_id": bson.M{"$in": ids}},
+	}}
+}
+
+// rankedMoviesStages 返回排序并限制数量的聚合阶段，供推荐相关查询共用
+// 选择了字段时追加只包含这些字段的投影
+func rankedMoviesStages(s movieSort, limit int64, fields []string) mongo.Pipeline {
+	stages := append(movieSortStages(s), bson.D{{Key: "$limit", Value: limit}})
+	if fields != nil {
+		stages = append(stages, bson.D{{Key: "$project", Value: movieFieldsProjection(fields)}})
+	}
+	return stages
+}
+
+// findRecommendedMovies 查询属于给定类型的推荐电影，类型列表为空时不按类型过滤
+// 按排名值升序排序（值越小排名越高），未排名的电影视为最差排在最后，并限制返回数量；
+// 排名相同时按 imdb_id 和 _id 排序，保证相同输入总是得到相同的结果，便于复现和排查
+// includeUnranked 为 false 时排除"未排名"哨兵值以及缺失排名的电影
+func findRecommendedMovies(ctx context.Context, client *mongo.Client, genres []models.Genre, limit int64, fields []string, includeUnranked bool) ([]models.Movie, error) {
+	// 构建过滤条件：电影类型在给定的类型列表中
+	// 没有喜爱类型的新用户按空列表过滤会匹配不到任何电影，此时退回全站排名最高的电影，避免冷启动时推荐为空
+	filter := bson.M{}
+	if len(genres) > 0 {
+		filter = genreMatchFilter(genres)
+	}
+	if !includeUnranked {
+		// $nin 中的 null 同时匹配缺失的字段
+		filter = bson.M{"$and": bson.A{filter, bson.M{"ranking.ranking_value": bson.M{"$nin": bson.A{unrankedRankingValue(), nil}}}}}
+	}
+
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: filter}}}, rankedMoviesStages(movieSort{Field: "ranking"}, limit, fields)...)
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+	// 执行数据库查询并解码到推荐电影列表中
+	var recommendedMovies []models.Movie
+	if err := database.AggregateAll(ctx, movieCollection, pipeline, &recommendedMovies); err != nil {
+		return nil, err
+	}
+	return recommendedMovies, nil
 }
 
 // GetUserFavouriteGenres 获取用户喜欢的电影类型列表
 // 参数: userId - 用户ID
-// 返回: 类型名称字符串切片, 错误信息
-func GetUserFavouriteGenres(userId string, client *mongo.Client, c *gin.Context) ([]string, error) {
+// 返回: 类型列表（包含 genre_id 和 genre_name，缺失的字段为零值）, 错误信息
+func GetUserFavouriteGenres(userId string, client *mongo.Client, c *gin.Context) ([]models.Genre, error) {
 	// 创建带超时的数据库操作上下文
 	var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 	defer cancel()
@@ -373,47 +1652,53 @@ func GetUserFavouriteGenres(userId string, client *mongo.Client, c *gin.Context)
 	// 构建查询条件和投影
 	filter := bson.M{"user_id": userId}
 	projection := bson.M{
-		"favourite_genres.genre_name": 1, // 只返回喜欢的类型名称
+		"favourite_genres.genre_id":   1, // 只返回喜欢的类型 ID
+		"favourite_genres.genre_name": 1, // 和类型名称
 		"_id":                         0, // 不返回_id字段
 	}
 	opts := options.FindOne().SetProjection(projection)
 
-	// 执行数据库查询
-	var result bson.M
+	// 解码到类型化的结构体，不依赖数组元素被解码为 bson.D 还是 bson.M；
+	// genre_id 解码为 any，兼容不同客户端写入的 int32、int64 和 double
+	var result struct {
+		FavouriteGenres []struct {
+			GenreID   any    `bson:"genre_id"`
+			GenreName string `bson:"genre_name"`
+		} `bson:"favourite_genres"`
+	}
 	var userCollection *mongo.Collection = database.OpenCollection("users", client)
-	err := userCollection.FindOne(ctx, filter, opts).Decode(&result)
+	err := database.FindOne(ctx, userCollection, filter, &result, opts)
 	if err != nil {
 		// 如果找不到用户文档，返回空切片
 		if err == mongo.ErrNoDocuments {
-			return []string{}, nil
+			return []models.Genre{}, nil
 		}
 		return nil, err
 	}
 
-	// 将favourite_genres字段转换为BSON数组
-	favGenresArray, ok := result["favourite_genres"].(bson.A)
-	if !ok {
-		return []string{}, errors.New("favourite_genres is not an array")
-	}
-
-	// 遍历数组提取所有类型名称
-	var genreName []string
-	for _, item := range favGenresArray {
-		// 将数组项转换为BSON文档
-		if genreMap, ok := item.(bson.D); ok {
-			// 遍历文档中的所有字段
-			for _, elem := range genreMap {
-				// 查找genre_name字段
-				if elem.Key == "genre_name" {
-					if name, ok := elem.Value.(string); ok {
-						genreName = append(genreName, name)
-					}
-				}
-			}
+	// 提取所有类型的 ID 和名称，两者都缺失的项忽略；字段缺失或为 null 时返回空切片
+	genres := make([]models.Genre, 0, len(result.FavouriteGenres))
+	for _, item := range result.FavouriteGenres {
+		genre := models.Genre{GenreID: bsonInt(item.GenreID), GenreName: item.GenreName}
+		if genre.GenreName != "" || genre.GenreID != 0 {
+			genres = append(genres, genre)
 		}
 	}
 
-	return genreName, nil
+	return genres, nil
+}
+
+// bsonInt 将 BSON 中可能出现的各种数字类型转换为 int，无法转换时返回 0
+func bsonInt(value any) int {
+	switch v := value.(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
 }
 
 func GetGenre(client *mongo.Client) gin.HandlerFunc {
@@ -422,16 +1707,206 @@ func GetGenre(client *mongo.Client) gin.HandlerFunc {
 		defer cancel()
 		var genres []models.Genre
 		var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
-		cursor, err := genreCollection.Find(ctx, bson.M{})
-		if err != nil {
+		if err := database.FindAll(ctx, genreCollection, bson.M{}, &genres); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching genres"})
 			return
 		}
-		defer cursor.Close(ctx)
-		if err := cursor.All(ctx, &genres); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding genres"})
+		c.JSON(http.StatusOK, genres)
+	}
+}
+
+// GetGenreNames 获取类型名称列表的处理器函数
+// 返回按字母排序的类型名称字符串数组，供下拉框等客户端校验使用；
+// 类型很少变化，结果缓存 GENRE_NAMES_CACHE_TTL_SECONDS 秒（默认 300 秒），X-Cache 响应头标明是否命中
+func GetGenreNames(client *mongo.Client) gin.HandlerFunc {
+	ttl := 300 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("GENRE_NAMES_CACHE_TTL_SECONDS")); err == nil && value > 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	namesCache := cache.New[[]string](ttl, 1)
+
+	return func(c *gin.Context) {
+		if names, ok := namesCache.Get("genre_names"); ok {
+			c.Header("X-Cache", "HIT")
+			c.JSON(http.StatusOK, names)
 			return
 		}
-		c.JSON(http.StatusOK, genres)
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+		findOptions := options.Find().
+			SetProjection(bson.M{"genre_name": 1}).
+			SetSort(bson.D{{Key: "genre_name", Value: 1}})
+		var genres []models.Genre
+		if err := database.FindAll(ctx, genreCollection, bson.M{}, &genres, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching genres"})
+			return
+		}
+
+		names := make([]string, 0, len(genres))
+		for _, genre := range genres {
+			names = append(names, genre.GenreName)
+		}
+
+		namesCache.Set("genre_names", names)
+		c.Header("X-Cache", "MISS")
+		c.JSON(http.StatusOK, names)
+	}
+}
+
+// ListRankings 获取所有排名等级的处理器函数（公开只读）
+// 按排名值升序返回，前端据此将电影的 ranking_value 映射为徽章样式
+func ListRankings(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "ranking_value", Value: 1}})
+		rankings := []models.Ranking{}
+		if err := database.FindAll(ctx, rankingCollection, bson.M{}, &rankings, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+			return
+		}
+		c.JSON(http.StatusOK, rankings)
+	}
+}
+
+// GetFeaturedGenres 获取带代表电影的类型列表的处理器函数
+// 每个类型取排名最高的一部电影的标题和海报作为浏览页的类型卡片，没有电影的类型不返回
+// 通过一次聚合完成，避免前端为每个类型单独请求
+// 结果优先读取后台任务预先计算的缓存（X-Computed-At 响应头为计算时间），缓存不存在时实时计算
+func GetFeaturedGenres(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var cached featuredGenresCache
+		err := readComputedCache(ctx, client, featuredGenresCacheKey, &cached)
+		if err == nil {
+			c.Header("X-Computed-At", cached.ComputedAt.Format(time.RFC3339))
+			c.JSON(http.StatusOK, cached.Genres)
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logger.Error("error reading featured genres cache", "error", err)
+		}
+
+		featured, err := computeFeaturedGenres(ctx, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching featured genres"})
+			return
+		}
+
+		c.Header("X-Computed-At", time.Now().Format(time.RFC3339))
+		c.JSON(http.StatusOK, featured)
+	}
+}
+
+// computeFeaturedGenres 通过聚合计算每个类型排名最高的电影
+func computeFeaturedGenres(ctx context.Context, client *mongo.Client) ([]models.FeaturedGenre, error) {
+	pipeline := mongo.Pipeline{
+		// 先按排名排序，展开类型后每组的第一条即为该类型排名最高的电影
+		rankingSortKeyStage(),
+		{{Key: "$sort", Value: bson.D{{Key: "ranking_sort_key", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$unwind", Value: "$genre"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         "$genre.genre_name",
+			"genre_id":    bson.M{"$first": "$genre.genre_id"},
+			"imdb_id":     bson.M{"$first": "$imdb_id"},
+			"title":       bson.M{"$first": "$title"},
+			"poster_path": bson.M{"$first": "$poster_path"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"genre_name":  "$_id",
+			"genre_id":    1,
+			"imdb_id":     1,
+			"title":       1,
+			"poster_path": 1,
+		}}},
+	}
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+	featured := []models.FeaturedGenre{}
+	if err := database.AggregateAll(ctx, movieCollection, pipeline, &featured); err != nil {
+		return nil, err
+	}
+	return featured, nil
+}
+
+// searchResultLimit 搜索接口返回的最大结果数
+const searchResultLimit = 50
+
+// GetMovieSearch 电影全文搜索的处理器函数
+// GET /movies/search?q=...，使用 movies 集合上的文本索引，按相关度（textScore）排序
+// 搜索开销较大且容易被抓取，因此按规范化后的查询字符串缓存结果（SEARCH_CACHE_TTL_SECONDS，默认 60 秒），
+// 命中缓存时不访问数据库，并通过 X-Cache 响应头标明是否命中。电影变更后由 TTL 自然淘汰旧结果。
+// 支持与 GetMovies 相同的 tag、genre、actor、director 过滤参数；sort 默认为 relevance，也可以是 sortableMovieFields 中的字段
+func GetMovieSearch(client *mongo.Client) gin.HandlerFunc {
+	ttl := 60 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("SEARCH_CACHE_TTL_SECONDS")); err == nil && value > 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	searchCache := cache.New[[]models.Movie](ttl, 1000)
+
+	return func(c *gin.Context) {
+		// 规范化查询：去除多余空白并转为小写，使等价查询命中同一缓存项
+		query := strings.ToLower(strings.Join(strings.Fields(c.Query("q")), " "))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query q is required"})
+			return
+		}
+
+		sortSpec, ok := parseMovieSort(c, "relevance", "relevance")
+		if !ok {
+			return
+		}
+		filter, ok := parseMovieFilters(c)
+		if !ok {
+			return
+		}
+		filter["$text"] = bson.M{"$search": query}
+
+		// 缓存键包含排序和过滤条件，避免不同参数的请求互相命中
+		// fmt 按键排序输出 map，过滤条件相同的请求得到相同的键
+		cacheKey := fmt.Sprintf("%s|%t|%v", sortSpec.Field, sortSpec.Descending, filter)
+		if movies, ok := searchCache.Get(cacheKey); ok {
+			c.Header("X-Cache", "HIT")
+			c.JSON(http.StatusOK, movies)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+		if sortSpec.Field == "relevance" {
+			pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}}})
+		} else {
+			pipeline = append(pipeline, movieSortStages(sortSpec)...)
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: searchResultLimit}})
+
+		movies := []models.Movie{}
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &movies); err != nil {
+			// 文本索引缺失时搜索无法工作，明确提示运维人员创建索引（重启服务或调用 POST /admin/indexes/ensure）
+			if database.IsIndexNotFound(err) {
+				logger.Error("movie text index missing, search unavailable; create the movie_text index by restarting the server or calling POST /admin/indexes/ensure",
+					"collection", "movies", "index", "movie_text", "error", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search is temporarily unavailable", "code": "SEARCH_INDEX_MISSING"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching movies"})
+			return
+		}
+
+		searchCache.Set(cacheKey, movies)
+		c.Header("X-Cache", "MISS")
+		c.JSON(http.StatusOK, movies)
 	}
 }
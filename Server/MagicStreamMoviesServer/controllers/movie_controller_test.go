@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestAddMovieUpsertRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/addmovie", withIdentity("user-1", "USER"), AddMovie(offlineClient(t)))
+
+	body := `{"imdb_id":"tt0000001","title":"Example","poster_path":"https://example.com/p.jpg","youtube_id":"abc","genre":[{"genre_id":1,"genre_name":"Drama"}],"admin_review":"","ranking":{"ranking_value":1,"ranking_name":"Excellent"}}`
+	req := httptest.NewRequest(http.MethodPost, "/addmovie?upsert=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusUnauthorized, w.Body.String())
+	}
+}
+
+// upsertBody 返回 upsert 请求体，genre 使用 seedCatalog 写入的 Drama
+func upsertBody(imdbId, title string) gin.H {
+	return gin.H{
+		"imdb_id":      imdbId,
+		"title":        title,
+		"poster_path":  "https://example.com/p.jpg",
+		"youtube_id":   "abc",
+		"genre":        []models.Genre{dramaGenre},
+		"admin_review": "",
+		"ranking":      models.Ranking{RankingValue: 1, RankingName: "Excellent"},
+	}
+}
+
+// findTestMovie 直接从数据库读取电影
+func findTestMovie(t *testing.T, client *mongo.Client, imdbId string) models.Movie {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var movie models.Movie
+	if err := database.FindOne(ctx, database.OpenCollection("movies", client), bson.M{"imdb_id": imdbId}, &movie); err != nil {
+		t.Fatalf("find movie %s: %v", imdbId, err)
+	}
+	return movie
+}
+
+func TestUpsertMovie(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	movieCollection := database.OpenCollection("movies", client)
+	if _, err := movieCollection.UpdateOne(ctx, bson.M{"imdb_id": "tt1"}, bson.M{"$set": bson.M{"locked": true}}); err != nil {
+		t.Fatalf("lock tt1: %v", err)
+	}
+	if _, err := movieCollection.UpdateOne(ctx, bson.M{"imdb_id": "tt3"}, bson.M{"$set": bson.M{"user_rating_count": 2, "user_rating_sum": 9, "tags": []string{"old"}}}); err != nil {
+		t.Fatalf("seed tt3 ratings: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/addmovie", withIdentity("admin-1", models.RoleAdmin), AddMovie(client))
+
+	// 已锁定的电影在同一次写操作中被拒绝，所有字段保持原值
+	w := performJSON(router, http.MethodPost, "/addmovie?upsert=true", upsertBody("tt1", "Overwritten"))
+	if w.Code != http.StatusLocked {
+		t.Errorf("locked upsert status = %d, want 423; body %s", w.Code, w.Body.String())
+	}
+	if movie := findTestMovie(t, client, "tt1"); movie.Title != "Drama One" || !movie.Locked {
+		t.Errorf("locked movie after upsert = %q locked=%v, want it unchanged", movie.Title, movie.Locked)
+	}
+
+	// 更新已有电影：以 $ 开头的标题按字面值保存，未提供的标签被移除，评分聚合保留
+	w = performJSON(router, http.MethodPost, "/addmovie?upsert=true", upsertBody("tt3", "$title"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update upsert status = %d, want 200; body %s", w.Code, w.Body.String())
+	}
+	movie := findTestMovie(t, client, "tt3")
+	if movie.Title != "$title" || len(movie.Tags) != 0 || movie.UserRatingCount != 2 || movie.UserRatingSum != 9 {
+		t.Errorf("updated movie = title %q tags %v ratings %d/%d, want $title, no tags, ratings 2/9", movie.Title, movie.Tags, movie.UserRatingCount, movie.UserRatingSum)
+	}
+
+	// 新建电影返回 201 和新文档的 _id
+	w = performJSON(router, http.MethodPost, "/addmovie?upsert=true", upsertBody("tt5", "New Drama"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("insert upsert status = %d, want 201; body %s", w.Code, w.Body.String())
+	}
+	var result mongo.UpdateResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || result.UpsertedCount != 1 {
+		t.Fatalf("insert upsert result = %+v (%v), want UpsertedCount 1", result, err)
+	}
+	created := findTestMovie(t, client, "tt5")
+	if created.ID.Hex() != result.UpsertedID {
+		t.Errorf("UpsertedID = %v, want %s", result.UpsertedID, created.ID.Hex())
+	}
+	if created.CreatedAt.IsZero() || created.Locked {
+		t.Errorf("created movie = created_at %v locked %v, want a creation time and unlocked", created.CreatedAt, created.Locked)
+	}
+}
+
+func TestGetMoviesCursorStopsOnLastPage(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	router := gin.New()
+	router.GET("/movies", GetMovies(client))
+	router.GET("/api/v1/movies", StructuredMovieResponses(), GetMovies(client))
+
+	type page struct {
+		Movies     []json.RawMessage `json:"movies"`
+		NextCursor string            `json:"next_cursor"`
+	}
+	fetch := func(path string) page {
+		t.Helper()
+		w := performJSON(router, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d; body %s", path, w.Code, w.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("decode %s: %v; body %s", path, err, w.Body.String())
+		}
+		return p
+	}
+
+	// 共 5 部电影：按 2 条翻页，最后一页不满时没有游标
+	for _, tc := range []struct {
+		path       string
+		wantCount  int
+		wantCursor string
+	}{
+		{"/movies?limit=2", 2, "tt2"},
+		{"/movies?limit=2&after=tt2", 2, "tt4"},
+		{"/movies?limit=2&after=tt4", 1, ""},
+		// 恰好取完全部电影时也不返回指向空页的游标
+		{"/movies?limit=5", 5, ""},
+		{"/api/v1/movies?limit=5", 5, ""},
+		{"/api/v1/movies?limit=4", 4, "tt4"},
+		{"/movies?limit=2&sort=imdb_id:desc", 2, "tt4"},
+		// 按其它字段排序时 after 不可用，也不返回游标
+		{"/movies?limit=2&sort=title:asc", 2, ""},
+		{"/api/v1/movies?limit=2&sort=title:asc", 2, ""},
+	} {
+		p := fetch(tc.path)
+		if len(p.Movies) != tc.wantCount || p.NextCursor != tc.wantCursor {
+			t.Errorf("GET %s = %d movies, next_cursor %q; want %d, %q", tc.path, len(p.Movies), p.NextCursor, tc.wantCount, tc.wantCursor)
+		}
+	}
+
+	if w := performJSON(router, http.MethodGet, "/movies?limit=2&sort=title:asc&after=tt2", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("after with title sort status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// unlockedMovieFilter 只匹配未锁定电影的过滤条件，修改电影的写操作都应使用它，
+// 使锁定检查和写入在同一次原子操作中完成
+func unlockedMovieFilter(imdbId string) bson.M {
+	return bson.M{"imdb_id": imdbId, "locked": bson.M{"$ne": true}}
+}
+
+// respondMovieLocked 写入 423 响应
+func respondMovieLocked(c *gin.Context, imdbId string) {
+	c.JSON(http.StatusLocked, gin.H{"error": "Movie is locked", "code": "MOVIE_LOCKED", "imdb_id": imdbId})
+}
+
+// respondMovieNotUpdated 使用 unlockedMovieFilter 的写操作没有匹配到电影时调用，
+// 区分电影不存在（404）和电影已锁定（423）并写入对应响应
+func respondMovieNotUpdated(ctx context.Context, c *gin.Context, movieCollection *mongo.Collection, imdbId string) {
+	var movie models.Movie
+	err := database.FindOne(ctx, movieCollection, bson.M{"imdb_id": imdbId}, &movie,
+		options.FindOne().SetProjection(bson.M{"locked": 1}))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+		return
+	}
+	if movie.Locked {
+		respondMovieLocked(c, imdbId)
+		return
+	}
+	// 检查期间电影被解锁，让客户端重试
+	c.JSON(http.StatusConflict, gin.H{"error": "Movie changed during update, please retry"})
+}
+
+// checkMovieUnlocked 在执行代价较高的操作（例如调用 AI）之前预先检查电影是否被锁定
+// 电影已锁定时写入 423 响应并返回 false；电影不存在时返回 true，由后续写操作报告 404
+func checkMovieUnlocked(ctx context.Context, c *gin.Context, movieCollection *mongo.Collection, imdbId string) bool {
+	count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": imdbId, "locked": true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking movie"})
+		return false
+	}
+	if count > 0 {
+		respondMovieLocked(c, imdbId)
+		return false
+	}
+	return true
+}
+
+// SetMovieLock 锁定或解锁电影的处理器函数（仅管理员）
+// 请求体为 {"locked": true|false}。锁定的电影是人工定稿的条目：UpdateMovie、AdminReviewUpdate、
+// 手动设置排名、标签修改和 upsert 都会返回 423，批量排名导入会跳过这些电影，需要修改时先解锁
+func SetMovieLock(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var req struct {
+			Locked *bool `json:"locked" validate:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movie models.Movie
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		err := movieCollection.FindOneAndUpdate(ctx,
+			bson.M{"imdb_id": movieId},
+			bson.M{"$set": bson.M{"locked": *req.Locked, "updated_at": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&movie)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
+
+		invalidateRecommendations()
+		actorId, _ := utils.GetUserIdFromContext(c)
+		recordAudit(ctx, client, models.AuditActionUpdate, actorId, movieId, bson.M{
+			"fields": []string{"locked"},
+			"locked": *req.Locked,
+		})
+
+		c.JSON(http.StatusOK, movie)
+	}
+}
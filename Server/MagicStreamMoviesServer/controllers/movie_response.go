@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// structuredMoviesKey 上下文中标记需要返回结构化电影的键
+const structuredMoviesKey = "structuredMovies"
+
+// movieFieldGroups 扁平字段在结构化表示中所属的分组，与 models.StructuredMovie 保持一致
+// 未列出的字段（_id）保留在顶层
+var movieFieldGroups = map[string]string{
+	"imdb_id":           "metadata",
+	"title":             "metadata",
+	"description":       "metadata",
+	"poster_path":       "metadata",
+	"youtube_id":        "metadata",
+	"genre":             "metadata",
+	"tags":              "metadata",
+	"cast":              "metadata",
+	"directors":         "metadata",
+	"created_at":        "metadata",
+	"updated_at":        "metadata",
+	"release_date":      "metadata",
+	"admin_review":      "editorial",
+	"ranking":           "editorial",
+	"locked":            "editorial",
+	"user_rating_avg":   "stats",
+	"user_rating_count": "stats",
+}
+
+// StructuredMovieResponses 让后续处理器返回结构化电影（metadata/editorial/stats）的中间件，用于 /api/v1 路由组
+// editorial 分组只返回给管理员
+func StructuredMovieResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(structuredMoviesKey, true)
+		c.Next()
+	}
+}
+
+// structuredMovies 判断当前请求是否需要返回结构化电影
+func structuredMovies(c *gin.Context) bool {
+	return c.GetBool(structuredMoviesKey)
+}
+
+// editorialVisible 判断当前请求能否看到结构化电影的 editorial 分组（管理员评论、排名、锁定状态），只有管理员可以
+func editorialVisible(c *gin.Context) bool {
+	role, err := utils.GetRoleFromContext(c)
+	return err == nil && models.IsAdminRole(role)
+}
+
+// structuredMovie 返回电影的结构化表示，非管理员请求去掉 editorial 分组
+func structuredMovie(c *gin.Context, movie models.Movie) models.StructuredMovie {
+	structured := movie.Structured()
+	if !editorialVisible(c) {
+		structured.Editorial = nil
+	}
+	return structured
+}
+
+// respondMovie 返回单部电影，/api/v1 路由返回结构化表示，其余路由返回扁平文档
+func respondMovie(c *gin.Context, movie models.Movie) {
+	if structuredMovies(c) {
+		c.JSON(http.StatusOK, structuredMovie(c, movie))
+		return
+	}
+	c.JSON(http.StatusOK, movie)
+}
+
+// groupMovieFields 将 fields 参数选择出的扁平字段按 movieFieldGroups 嵌套到对应分组中
+// showEditorial 为 false 时丢弃属于 editorial 分组的字段
+func groupMovieFields(item map[string]any, showEditorial bool) map[string]any {
+	grouped := make(map[string]any)
+	for field, value := range item {
+		group, ok := movieFieldGroups[field]
+		if !ok {
+			grouped[field] = value
+			continue
+		}
+		if group == "editorial" && !showEditorial {
+			continue
+		}
+		section, _ := grouped[group].(map[string]any)
+		if section == nil {
+			section = make(map[string]any)
+			grouped[group] = section
+		}
+		section[field] = value
+	}
+	return grouped
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+)
+
+// structuredTestRouter 返回 /api/v1 风格的路由：/movie 返回单部电影，/movies 按 fields 参数返回电影列表
+func structuredTestRouter(role string) *gin.Engine {
+	movie := models.Movie{
+		ImdbID:      "tt1",
+		Title:       "Drama One",
+		AdminReview: "internal notes",
+		Ranking:     models.Ranking{RankingValue: 1, RankingName: "Excellent"},
+		Locked:      true,
+	}
+	router := gin.New()
+	v1 := router.Group("/api/v1", withIdentity("user-1", role), StructuredMovieResponses())
+	v1.GET("/movie", func(c *gin.Context) { respondMovie(c, movie) })
+	v1.GET("/movies", func(c *gin.Context) {
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+		respondMovies(c, []models.Movie{movie}, fields)
+	})
+	return router
+}
+
+func TestStructuredMovieEditorialVisibility(t *testing.T) {
+	for _, tc := range []struct {
+		role          string
+		wantEditorial bool
+	}{
+		{models.RoleUser, false},
+		{models.RoleAdmin, true},
+	} {
+		router := structuredTestRouter(tc.role)
+		for _, path := range []string{"/api/v1/movie", "/api/v1/movies", "/api/v1/movies?fields=title,admin_review,locked"} {
+			w := performJSON(router, http.MethodGet, path, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s GET %s status = %d; body %s", tc.role, path, w.Code, w.Body.String())
+			}
+
+			var item map[string]any
+			if path == "/api/v1/movie" {
+				if err := json.Unmarshal(w.Body.Bytes(), &item); err != nil {
+					t.Fatalf("decode %s: %v", path, err)
+				}
+			} else {
+				var items []map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil || len(items) != 1 {
+					t.Fatalf("decode %s: %v; body %s", path, err, w.Body.String())
+				}
+				item = items[0]
+			}
+
+			if _, ok := item["metadata"]; !ok {
+				t.Errorf("%s GET %s: metadata missing from %s", tc.role, path, w.Body.String())
+			}
+			editorial, ok := item["editorial"].(map[string]any)
+			if ok != tc.wantEditorial {
+				t.Errorf("%s GET %s: editorial present = %v, want %v; body %s", tc.role, path, ok, tc.wantEditorial, w.Body.String())
+			}
+			if tc.wantEditorial && editorial["admin_review"] != "internal notes" {
+				t.Errorf("%s GET %s: admin_review = %v, want the stored review", tc.role, path, editorial["admin_review"])
+			}
+		}
+	}
+}
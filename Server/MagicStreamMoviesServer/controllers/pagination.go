@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// maxPageLimit 分页接口单页允许的最大条数
+const maxPageLimit = 100
+
+// maxCursorLength 游标令牌允许的最大长度，正常编码的游标远小于该值
+const maxCursorLength = 256
+
+// moviesPageMaxLimit 返回 GET /movies 单页允许的最大条数，也是未传入 limit 时的默认值
+// 优先读取环境变量 MOVIES_PAGE_MAX_LIMIT，默认 50
+func moviesPageMaxLimit() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MOVIES_PAGE_MAX_LIMIT"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return 50
+}
+
+// parsePagination 解析 page（默认 1）和 limit（默认 20，最大 maxPageLimit）查询参数
+// 参数非法时直接写入 400 响应并返回 ok=false
+func parsePagination(c *gin.Context) (page, limit int64, ok bool) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+		return 0, 0, false
+	}
+	limit, err = strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return 0, 0, false
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit, true
+}
+
+// pageCursor 游标分页的位置：上一页最后一条记录的排序时间和 _id
+type pageCursor struct {
+	Time time.Time
+	ID   bson.ObjectID
+}
+
+// cursorToken 游标令牌的载荷，编码为 base64url 的 JSON，对客户端来说是不透明的
+type cursorToken struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// encodeCursor 将上一页最后一条记录的位置编码为游标令牌
+func encodeCursor(t time.Time, id bson.ObjectID) string {
+	data, _ := json.Marshal(cursorToken{Time: t, ID: id.Hex()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseCursor 解析 cursor 查询参数
+// 未传入时返回 ok=true 且 cursor 为 nil；令牌过长、无法解码或结构不符时写入 400 响应并返回 ok=false，
+// 而不是报 500 或悄悄从第一页开始
+func parseCursor(c *gin.Context) (cursor *pageCursor, ok bool) {
+	value := c.Query("cursor")
+	if value == "" {
+		return nil, true
+	}
+	if len(value) > maxCursorLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor is too long", "code": "CURSOR_TOO_LONG", "max": maxCursorLength})
+		return nil, false
+	}
+
+	invalid := func(details string) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor", "code": "INVALID_CURSOR", "details": details})
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		invalid("cursor is not valid base64url")
+		return nil, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	var decoded cursorToken
+	if err := decoder.Decode(&decoded); err != nil {
+		invalid("cursor does not decode to the expected shape")
+		return nil, false
+	}
+	if decoded.Time.IsZero() {
+		invalid("cursor is missing its position timestamp")
+		return nil, false
+	}
+	id, err := bson.ObjectIDFromHex(decoded.ID)
+	if err != nil {
+		invalid("cursor id is not a valid ObjectID")
+		return nil, false
+	}
+	return &pageCursor{Time: decoded.Time, ID: id}, true
+}
+
+// cursorFilter 返回按 timeField、_id 倒序排列时位于游标之后的记录的过滤条件
+func cursorFilter(timeField string, cursor *pageCursor) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{timeField: bson.M{"$lt": cursor.Time}},
+		bson.M{timeField: cursor.Time, "_id": bson.M{"$lt": cursor.ID}},
+	}}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// normalizePersonName 规范化人名用于匹配：转为小写并合并连续空白，
+// 使 "Tom  Hanks" 与 "tom hanks" 视为同一个人
+func normalizePersonName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// normalizeCast 去除演员姓名和角色的首尾空白，拒绝空姓名，
+// 同一演员（姓名忽略大小写）饰演同一角色的重复项只保留第一个
+func normalizeCast(cast []models.CastMember) ([]models.CastMember, error) {
+	seen := make(map[string]bool, len(cast))
+	normalized := make([]models.CastMember, 0, len(cast))
+	for _, member := range cast {
+		member.Name = strings.Join(strings.Fields(member.Name), " ")
+		member.Role = strings.TrimSpace(member.Role)
+		if member.Name == "" {
+			return nil, errors.New("cast name must not be empty")
+		}
+		key := normalizePersonName(member.Name) + "\x00" + strings.ToLower(member.Role)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, member)
+	}
+	return normalized, nil
+}
+
+// normalizeDirectors 去除导演姓名的首尾空白，拒绝空姓名，并按姓名（不区分大小写）去重
+func normalizeDirectors(directors []string) ([]string, error) {
+	seen := make(map[string]bool, len(directors))
+	normalized := make([]string, 0, len(directors))
+	for _, director := range directors {
+		director = strings.Join(strings.Fields(director), " ")
+		if director == "" {
+			return nil, errors.New("director name must not be empty")
+		}
+		key := normalizePersonName(director)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, director)
+	}
+	return normalized, nil
+}
+
+// castKeys 返回演员的规范化姓名列表（去重），存储在 cast_keys 中供过滤和索引使用
+func castKeys(cast []models.CastMember) []string {
+	names := make([]string, 0, len(cast))
+	for _, member := range cast {
+		names = append(names, member.Name)
+	}
+	return personKeys(names)
+}
+
+// personKeys 返回规范化并去重后的姓名列表
+func personKeys(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		key := normalizePersonName(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// maxPersonMovies 人物作品列表最多返回的电影数
+const maxPersonMovies = 500
+
+// GetPersonMovies 获取某个人物参与的所有电影的处理器函数
+// GET /people/:name/movies，返回 name 出现在演员或导演中的电影，姓名按规范化后不区分大小写匹配；
+// 找不到该人物时返回空列表而不是 404。sort 默认为 ranking:asc（排名最高的在前），
+// 也可以是 year（最新的在前，按 release_date，没有上线日期的按 created_at）或 sortableMovieFields 中的字段；
+// 支持 fields 参数裁剪返回字段
+func GetPersonMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := normalizePersonName(c.Param("name"))
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Person name required"})
+			return
+		}
+
+		sortSpec, ok := parseMovieSort(c, "ranking:asc", "year")
+		if !ok {
+			return
+		}
+		fields, ok := parseMovieFields(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"cast_keys": name},
+			bson.M{"director_keys": name},
+		}}}}}
+		if sortSpec.Field == "year" {
+			pipeline = append(pipeline,
+				bson.D{{Key: "$addFields", Value: bson.M{"year_sort_key": bson.M{"$ifNull": bson.A{"$release_date", "$created_at"}}}}},
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "year_sort_key", Value: -1}, {Key: "_id", Value: -1}}}},
+				bson.D{{Key: "$project", Value: bson.M{"year_sort_key": 0}}},
+				bson.D{{Key: "$limit", Value: maxPersonMovies}},
+			)
+			if fields != nil {
+				pipeline = append(pipeline, bson.D{{Key: "$project", Value: movieFieldsProjection(fields)}})
+			}
+		} else {
+			pipeline = append(pipeline, rankedMoviesStages(sortSpec, maxPersonMovies, fields)...)
+		}
+
+		movies := []models.Movie{}
+		if err := database.AggregateAll(ctx, movieCollection, pipeline, &movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
+			return
+		}
+
+		respondMovies(c, movies, fields)
+	}
+}
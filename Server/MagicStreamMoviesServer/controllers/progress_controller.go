@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// completedProgressRatio 播放进度达到时长的该比例即视为看完（片尾字幕通常不会被看完）
+const completedProgressRatio = 0.95
+
+// continueWatchingLimit "继续观看"列表返回的最大条数
+const continueWatchingLimit = 20
+
+// UpdateWatchProgress 更新当前用户某部电影播放进度的处理器函数
+// PUT /movie/:imdb_id/progress，请求体: {"position_seconds": 120, "duration_seconds": 5400}
+// 进度达到时长的 95% 时标记为已看完，已看完的电影不会出现在"继续观看"列表中
+func UpdateWatchProgress(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var progress models.WatchProgress
+		if err := c.ShouldBindJSON(&progress); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(progress); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+
+		// 进度不能超过总时长
+		if progress.PositionSeconds > progress.DurationSeconds {
+			progress.PositionSeconds = progress.DurationSeconds
+		}
+		progress.Completed = float64(progress.PositionSeconds) >= float64(progress.DurationSeconds)*completedProgressRatio
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		progress.UserID = userId
+		progress.ImdbID = movieId
+		progress.UpdatedAt = time.Now()
+
+		var progressCollection *mongo.Collection = database.OpenCollection("progress", client)
+		filter := bson.M{"user_id": userId, "imdb_id": movieId}
+		update := bson.M{"$set": bson.M{
+			"position_seconds": progress.PositionSeconds,
+			"duration_seconds": progress.DurationSeconds,
+			"completed":        progress.Completed,
+			"updated_at":       progress.UpdatedAt,
+		}}
+		if _, err := progressCollection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving progress"})
+			return
+		}
+
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// GetContinueWatching 获取当前用户"继续观看"列表的处理器函数
+// 返回已开始但未看完的电影及其播放进度，按最近观看时间倒序
+func GetContinueWatching(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"user_id":          userId,
+				"completed":        false,
+				"position_seconds": bson.M{"$gt": 0},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}}}},
+			{{Key: "$limit", Value: continueWatchingLimit}},
+			// 关联电影信息，电影已被删除的进度记录会在 $unwind 时被丢弃
+			{{Key: "$lookup", Value: bson.M{
+				"from":         "movies",
+				"localField":   "imdb_id",
+				"foreignField": "imdb_id",
+				"as":           "movie",
+			}}},
+			{{Key: "$unwind", Value: "$movie"}},
+		}
+
+		var progressCollection *mongo.Collection = database.OpenCollection("progress", client)
+		items := []models.ContinueWatchingItem{}
+		if err := database.AggregateAll(ctx, progressCollection, pipeline, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching continue watching list"})
+			return
+		}
+
+		c.JSON(http.StatusOK, items)
+	}
+}
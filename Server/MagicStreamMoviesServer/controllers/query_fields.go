@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// sortableMovieFields 允许客户端排序的电影字段，GetMovies、搜索和按类型推荐共用
+// 新增字段前应确认查询代价可以接受，避免任意字段排序导致无索引扫描或暴露内部字段
+var sortableMovieFields = map[string]bool{
+	"imdb_id":         true,
+	"created_at":      true,
+	"updated_at":      true,
+	"title":           true,
+	"ranking":         true, // 按 ranking.ranking_value，"未排名"和缺失排名的电影视为最差
+	"user_rating_avg": true, // 评分数量不足 MIN_USER_RATING_COUNT 的电影排在后面
+}
+
+// filterableMovieFields 允许客户端过滤的查询参数及其对应的电影字段
+var filterableMovieFields = map[string]string{
+	"tag":      "tags",
+	"genre":    "genre.genre_name",
+	"actor":    "cast_keys",
+	"director": "director_keys",
+}
+
+// movieSort 排序字段和方向
+type movieSort struct {
+	Field      string
+	Descending bool
+}
+
+// parseMovieSort 解析 field:asc|field:desc 格式的 sort 查询参数，未传入时使用 defaultSort
+// extra 为当前接口额外支持的非字段排序（如搜索的 relevance）；
+// 字段不在 sortableMovieFields 中时写入 400 响应并返回 ok=false
+func parseMovieSort(c *gin.Context, defaultSort string, extra ...string) (movieSort, bool) {
+	value := c.DefaultQuery("sort", defaultSort)
+	for _, name := range extra {
+		if value == name {
+			return movieSort{Field: name}, true
+		}
+	}
+
+	field, direction, found := strings.Cut(value, ":")
+	if !found {
+		direction = "asc"
+	}
+	if !sortableMovieFields[field] || (direction != "asc" && direction != "desc") {
+		allowed := make([]string, 0, len(sortableMovieFields)+len(extra))
+		for name := range sortableMovieFields {
+			allowed = append(allowed, name)
+		}
+		sort.Strings(allowed)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported sort, expected field:asc or field:desc",
+			"code":    "UNSUPPORTED_SORT",
+			"allowed": append(allowed, extra...),
+		})
+		return movieSort{}, false
+	}
+	return movieSort{Field: field, Descending: direction == "desc"}, true
+}
+
+// parseMovieFilters 根据 filterableMovieFields 中的查询参数构建过滤条件，没有过滤时返回空条件
+// 标签会先规范化，非法时写入 400 响应并返回 ok=false；演员和导演按规范化姓名匹配，不区分大小写
+// genre 可以重复传入（genre=Action&genre=Drama），匹配任意一个类型即可，类型名不区分大小写
+func parseMovieFilters(c *gin.Context) (bson.M, bool) {
+	filter := bson.M{}
+	for param, field := range filterableMovieFields {
+		if param == "genre" {
+			if genres := genreNameFilter(c.QueryArray(param)); genres != nil {
+				filter[field] = genres
+			}
+			continue
+		}
+
+		value := strings.TrimSpace(c.Query(param))
+		if value == "" {
+			continue
+		}
+		if param == "tag" {
+			normalized, err := normalizeTag(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag", "details": err.Error()})
+				return nil, false
+			}
+			value = normalized
+		}
+		if param == "actor" || param == "director" {
+			value = normalizePersonName(value)
+		}
+		filter[field] = value
+	}
+	return filter, true
+}
+
+// genreNameFilter 构建不区分大小写的类型名过滤条件，多个类型之间为"或"关系，没有有效类型时返回 nil
+// 类型名经过转义并整体匹配，避免用户输入被当作正则表达式解析
+func genreNameFilter(values []string) bson.M {
+	patterns := bson.A{}
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		name := strings.ToLower(strings.TrimSpace(value))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		patterns = append(patterns, bson.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"})
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	return bson.M{"$in": patterns}
+}
+
+// minUserRatingCount 返回按用户评分排序时参与正常排序所需的最少评分数量
+// 优先读取环境变量 MIN_USER_RATING_COUNT，默认 5
+func minUserRatingCount() int {
+	if value, err := strconv.Atoi(os.Getenv("MIN_USER_RATING_COUNT")); err == nil && value >= 0 {
+		return value
+	}
+	return 5
+}
+
+// movieSortStages 返回实现排序的聚合阶段，最后以 _id 兜底保证顺序稳定，并去掉排序用的临时字段
+func movieSortStages(s movieSort) mongo.Pipeline {
+	direction := 1
+	if s.Descending {
+		direction = -1
+	}
+
+	switch s.Field {
+	case "ranking":
+		return mongo.Pipeline{
+			rankingSortKeyStage(),
+			{{Key: "$sort", Value: bson.D{
+				{Key: "ranking_sort_key", Value: direction},
+				{Key: "imdb_id", Value: 1},
+				{Key: "_id", Value: 1},
+			}}},
+			{{Key: "$project", Value: bson.M{"ranking_sort_key": 0}}},
+		}
+	case "user_rating_avg":
+		// 评分数量足够的电影始终排在前面，避免只有一条五星评价的电影排在上百条 4.8 分的电影前面
+		return mongo.Pipeline{
+			{{Key: "$addFields", Value: bson.M{"user_rating_qualified": bson.M{"$gte": bson.A{
+				bson.M{"$ifNull": bson.A{"$user_rating_count", 0}},
+				minUserRatingCount(),
+			}}}}},
+			{{Key: "$sort", Value: bson.D{
+				{Key: "user_rating_qualified", Value: -1},
+				{Key: "user_rating_avg", Value: direction},
+				{Key: "user_rating_count", Value: -1},
+				{Key: "_id", Value: direction},
+			}}},
+			{{Key: "$project", Value: bson.M{"user_rating_qualified": 0}}},
+		}
+	default:
+		return mongo.Pipeline{
+			{{Key: "$sort", Value: bson.D{{Key: s.Field, Value: direction}, {Key: "_id", Value: direction}}}},
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxRankingImportRows 单次导入的最大行数
+const maxRankingImportRows = 5000
+
+// rankingImportRow 导入映射中的一行：imdb_id -> ranking_name
+type rankingImportRow struct {
+	ImdbID      string `json:"imdb_id"`
+	RankingName string `json:"ranking_name"`
+}
+
+// rankingImportResult 单行导入结果，Row 为该行在输入中的序号（从 1 开始）
+type rankingImportResult struct {
+	Row         int    `json:"row"`
+	ImdbID      string `json:"imdb_id"`
+	RankingName string `json:"ranking_name"`
+	Success     bool   `json:"success"`
+	Skipped     bool   `json:"skipped,omitempty"` // 电影已锁定，本行没有写入
+	Error       string `json:"error,omitempty"`
+}
+
+// ImportRankings 批量导入电影排名的处理器函数（仅管理员）
+// 请求体可以是 JSON 数组 [{"imdb_id": "...", "ranking_name": "..."}]，
+// 也可以是 Content-Type 为 text/csv 的 imdb_id,ranking_name 两列 CSV（表头可选）。
+// 每个 ranking_name 都必须存在于 rankings 集合中，直接写入电影的 ranking 字段，不调用 AI，
+// 已锁定的电影（包括导入过程中被锁定的）会被跳过并标记为 skipped，响应中逐行返回成功、跳过或失败原因。
+func ImportRankings(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var rows []rankingImportRow
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			parsed, err := parseRankingImportCSV(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV", "details": err.Error()})
+				return
+			}
+			rows = parsed
+		} else if err := c.ShouldBindJSON(&rows); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		if len(rows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No rows to import"})
+			return
+		}
+		if len(rows) > maxRankingImportRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many rows, at most %d allowed per import", maxRankingImportRows)})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var rankings []models.Ranking
+		var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+		if err := database.FindAll(ctx, rankingCollection, bson.M{}, &rankings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+			return
+		}
+		rankingsByName := make(map[string]models.Ranking, len(rankings))
+		for _, ranking := range rankings {
+			rankingsByName[ranking.RankingName] = ranking
+		}
+
+		// 预先查询存在的电影，以便对找不到的 imdb_id 逐行报告
+		imdbIds := make([]string, 0, len(rows))
+		for _, row := range rows {
+			imdbIds = append(imdbIds, strings.TrimSpace(row.ImdbID))
+		}
+		var existing []struct {
+			ImdbID string `bson:"imdb_id"`
+			Locked bool   `bson:"locked"`
+		}
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		err := database.FindAll(ctx, movieCollection, bson.M{"imdb_id": bson.M{"$in": imdbIds}}, &existing,
+			options.Find().SetProjection(bson.M{"imdb_id": 1, "locked": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
+			return
+		}
+		existingIds := make(map[string]bool, len(existing))
+		lockedIds := make(map[string]bool)
+		unlockedDocs := make(map[string]int64) // imdb_id 不是唯一索引，同一 imdb_id 可能有多份文档
+		for _, movie := range existing {
+			existingIds[movie.ImdbID] = true
+			if movie.Locked {
+				lockedIds[movie.ImdbID] = true
+			} else {
+				unlockedDocs[movie.ImdbID]++
+			}
+		}
+
+		results := make([]rankingImportResult, len(rows))
+		var writes []mongo.WriteModel
+		var writeRows []int // writes[i] 对应的 results 下标
+		var expectedMatches int64
+		now := time.Now()
+		for i, row := range rows {
+			imdbId := strings.TrimSpace(row.ImdbID)
+			rankingName := strings.TrimSpace(row.RankingName)
+			results[i] = rankingImportResult{Row: i + 1, ImdbID: imdbId, RankingName: rankingName}
+
+			ranking, ok := rankingsByName[rankingName]
+			switch {
+			case imdbId == "":
+				results[i].Error = "imdb_id is required"
+			case rankingName == "":
+				results[i].Error = "ranking_name is required"
+			case !ok:
+				results[i].Error = "Unknown ranking_name"
+			case !existingIds[imdbId]:
+				results[i].Error = "Movie not found"
+			case lockedIds[imdbId]:
+				results[i].Skipped = true
+				results[i].Error = "Movie is locked"
+			default:
+				writes = append(writes, mongo.NewUpdateManyModel().
+					SetFilter(unlockedMovieFilter(imdbId)).
+					SetUpdate(bson.M{"$set": bson.M{"ranking": ranking, "updated_at": now}}))
+				writeRows = append(writeRows, i)
+				expectedMatches += unlockedDocs[imdbId]
+				results[i].Success = true
+			}
+		}
+
+		if len(writes) > 0 {
+			bulkResult, err := movieCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+			var bulkErr mongo.BulkWriteException
+			if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+				// 部分写入失败：只把失败的行标记为失败
+				for _, writeErr := range bulkErr.WriteErrors {
+					row := writeRows[writeErr.Index]
+					results[row].Success = false
+					results[row].Error = "Error updating movie"
+				}
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movies"})
+				return
+			}
+
+			// 预查询之后电影被锁定或删除时 unlockedMovieFilter 不会匹配，写入不报错但 MatchedCount 少于预期，
+			// 这时重新查询这些电影，把没有写入的行标记为跳过或失败
+			if bulkResult != nil && bulkResult.MatchedCount < expectedMatches {
+				if err := markUnmatchedImportRows(ctx, movieCollection, results, writeRows); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies"})
+					return
+				}
+			}
+		}
+
+		invalidateRecommendations()
+		actorId, _ := utils.GetUserIdFromContext(c)
+		succeeded, skipped := 0, 0
+		for _, result := range results {
+			if result.Skipped {
+				skipped++
+			}
+			if !result.Success {
+				continue
+			}
+			succeeded++
+			recordAudit(ctx, client, models.AuditActionRank, actorId, result.ImdbID, bson.M{
+				"ranking_name":  result.RankingName,
+				"ranking_value": rankingsByName[result.RankingName].RankingValue,
+				"source":        "import",
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total":     len(results),
+			"succeeded": succeeded,
+			"skipped":   skipped,
+			"failed":    len(results) - succeeded - skipped,
+			"results":   results,
+		})
+	}
+}
+
+// markUnmatchedImportRows 重新查询已写入行对应的电影，把当前已锁定的行标记为跳过、已不存在的行标记为失败
+func markUnmatchedImportRows(ctx context.Context, movieCollection *mongo.Collection, results []rankingImportResult, writeRows []int) error {
+	imdbIds := make([]string, 0, len(writeRows))
+	for _, row := range writeRows {
+		imdbIds = append(imdbIds, results[row].ImdbID)
+	}
+	var current []struct {
+		ImdbID string `bson:"imdb_id"`
+		Locked bool   `bson:"locked"`
+	}
+	err := database.FindAll(ctx, movieCollection, bson.M{"imdb_id": bson.M{"$in": imdbIds}}, &current,
+		options.Find().SetProjection(bson.M{"imdb_id": 1, "locked": 1}))
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(current))
+	lockedIds := make(map[string]bool)
+	for _, movie := range current {
+		found[movie.ImdbID] = true
+		if movie.Locked {
+			lockedIds[movie.ImdbID] = true
+		}
+	}
+
+	for _, row := range writeRows {
+		result := &results[row]
+		if !result.Success {
+			continue
+		}
+		switch {
+		case lockedIds[result.ImdbID]:
+			result.Success = false
+			result.Skipped = true
+			result.Error = "Movie is locked"
+		case !found[result.ImdbID]:
+			result.Success = false
+			result.Error = "Movie not found"
+		}
+	}
+	return nil
+}
+
+// parseRankingImportCSV 解析 imdb_id,ranking_name 两列的 CSV
+// 第一行为 imdb_id,ranking_name 表头时跳过
+func parseRankingImportCSV(body io.Reader) ([]rankingImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]rankingImportRow, 0, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "imdb_id") {
+			continue
+		}
+		rows = append(rows, rankingImportRow{ImdbID: record[0], RankingName: record[1]})
+	}
+	return rows, nil
+}
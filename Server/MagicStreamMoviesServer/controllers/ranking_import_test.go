@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestImportRankingsSkipsLockedMovies(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := database.OpenCollection("rankings", client).InsertOne(ctx, models.Ranking{RankingValue: 2, RankingName: "Good"}); err != nil {
+		t.Fatalf("insert ranking: %v", err)
+	}
+	if _, err := database.OpenCollection("movies", client).UpdateOne(ctx, bson.M{"imdb_id": "tt1"}, bson.M{"$set": bson.M{"locked": true}}); err != nil {
+		t.Fatalf("lock tt1: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/admin/rankings/import", withIdentity("admin-1", models.RoleAdmin), ImportRankings(client))
+	w := performJSON(router, http.MethodPost, "/admin/rankings/import", []gin.H{
+		{"imdb_id": "tt1", "ranking_name": "Good"},
+		{"imdb_id": "tt3", "ranking_name": "Good"},
+		{"imdb_id": "tt404", "ranking_name": "Good"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("import status = %d; body %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Succeeded int                   `json:"succeeded"`
+		Skipped   int                   `json:"skipped"`
+		Failed    int                   `json:"failed"`
+		Results   []rankingImportResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode import response: %v", err)
+	}
+	if body.Succeeded != 1 || body.Skipped != 1 || body.Failed != 1 {
+		t.Errorf("summary = %d succeeded, %d skipped, %d failed; want 1/1/1", body.Succeeded, body.Skipped, body.Failed)
+	}
+	if !body.Results[0].Skipped || body.Results[0].Success {
+		t.Errorf("locked row = %+v, want skipped", body.Results[0])
+	}
+	if movie := findTestMovie(t, client, "tt1"); movie.Ranking.RankingName != "Excellent" {
+		t.Errorf("locked movie ranking = %q, want it unchanged", movie.Ranking.RankingName)
+	}
+}
+
+func TestMarkUnmatchedImportRows(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	movieCollection := database.OpenCollection("movies", client)
+	// 模拟预查询之后、写入之前 tt1 被锁定、tt2 被删除
+	if _, err := movieCollection.UpdateOne(ctx, bson.M{"imdb_id": "tt1"}, bson.M{"$set": bson.M{"locked": true}}); err != nil {
+		t.Fatalf("lock tt1: %v", err)
+	}
+	if _, err := movieCollection.DeleteOne(ctx, bson.M{"imdb_id": "tt2"}); err != nil {
+		t.Fatalf("delete tt2: %v", err)
+	}
+
+	results := []rankingImportResult{
+		{Row: 1, ImdbID: "tt1", Success: true},
+		{Row: 2, ImdbID: "tt2", Success: true},
+		{Row: 3, ImdbID: "tt3", Success: true},
+		{Row: 4, ImdbID: "", Error: "imdb_id is required"},
+	}
+	if err := markUnmatchedImportRows(ctx, movieCollection, results, []int{0, 1, 2}); err != nil {
+		t.Fatalf("markUnmatchedImportRows: %v", err)
+	}
+
+	if got := results[0]; got.Success || !got.Skipped || got.Error != "Movie is locked" {
+		t.Errorf("locked row = %+v, want skipped with Movie is locked", got)
+	}
+	if got := results[1]; got.Success || got.Skipped || got.Error != "Movie not found" {
+		t.Errorf("deleted row = %+v, want failed with Movie not found", got)
+	}
+	if got := results[2]; !got.Success {
+		t.Errorf("unchanged row = %+v, want success", got)
+	}
+	if got := results[3]; got.Success || got.Error != "imdb_id is required" {
+		t.Errorf("invalid row = %+v, want it untouched", got)
+	}
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+func TestNormalizeRankingText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Excellent.", "excellent"},
+		{"  'Good' ", "good"},
+		{"\"Not  Good\"\n", "not good"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeRankingText(tt.text); got != tt.want {
+			t.Errorf("normalizeRankingText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchRanking(t *testing.T) {
+	const unranked = 999
+	rankings := []models.Ranking{
+		{RankingValue: 1, RankingName: "Excellent"},
+		{RankingValue: 2, RankingName: "Good"},
+		{RankingValue: 3, RankingName: "Okay"},
+		{RankingValue: 4, RankingName: "Bad"},
+		{RankingValue: 5, RankingName: "Not Good"},
+		{RankingValue: unranked, RankingName: "Not_Ranked"},
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		ok       bool
+	}{
+		{"trailing punctuation", "Excellent.", "Excellent", true},
+		{"quotes and whitespace", "  'Good' ", "Good", true},
+		{"sentence prefers longest name", "The ranking is Not Good", "Not Good", true},
+		{"sentence with single name", "I would say this is okay overall", "Okay", true},
+		{"word inside another word", "Goodness", "", false},
+		{"unranked sentinel is never matched", "Not_Ranked", "", false},
+		{"unmatched", "Mediocre", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchRanking(tt.response, rankings, unranked)
+			if ok != tt.ok || got.RankingName != tt.want {
+				t.Errorf("matchRanking(%q) = %q, %v; want %q, %v", tt.response, got.RankingName, ok, tt.want, tt.ok)
+			}
+
+			_, err := rankingFromResponse(tt.response, rankings, unranked)
+			if tt.ok && err != nil {
+				t.Errorf("rankingFromResponse(%q) error = %v", tt.response, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrUnrecognizedRanking) {
+				t.Errorf("rankingFromResponse(%q) error = %v, want ErrUnrecognizedRanking", tt.response, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxRankingRetryBatch 单次重试处理的最大条目数，每条都要调用一次 AI
+const maxRankingRetryBatch = 20
+
+// rankingRetryWriteMargin 每条重试在 AI 调用之外为读取排名、写入电影和审计日志预留的时间
+const rankingRetryWriteMargin = 10 * time.Second
+
+// rankingRetryTimeout 单次重试请求的总时间，至少能完整处理一条（一次 AI 调用的最长时间加上数据库写入）
+func rankingRetryTimeout() time.Duration {
+	return max(100*time.Second, llmCallBudget()+rankingRetryWriteMargin)
+}
+
+// 重试结果状态
+const (
+	retryStatusRanked  = "ranked"
+	retryStatusFailed  = "failed"
+	retryStatusSkipped = "skipped"
+)
+
+// recordFailedRanking 记录一次失败的 AI 排名，供 RetryFailedRankings 重新处理
+// 同一部电影只保留最近一次提交的评论；写入失败只记录日志
+func recordFailedRanking(ctx context.Context, client *mongo.Client, imdbId, adminReview, actorId string, rankingErr error) {
+	now := time.Now()
+	var failedCollection *mongo.Collection = database.OpenCollection("failed_rankings", client)
+	_, err := failedCollection.UpdateOne(ctx,
+		bson.M{"imdb_id": imdbId},
+		bson.M{
+			"$set": bson.M{
+				"admin_review":    adminReview,
+				"last_error":      rankingErr.Error(),
+				"actor_id":        actorId,
+				"last_attempt_at": now,
+			},
+			"$inc":         bson.M{"attempts": 1},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		logger.Error("error recording failed ranking", "imdb_id", imdbId, "error", err)
+	}
+}
+
+// clearFailedRanking 电影的排名被成功设置后移除失败记录，避免之后的重试用旧评论覆盖新的排名
+func clearFailedRanking(ctx context.Context, client *mongo.Client, imdbId string) {
+	var failedCollection *mongo.Collection = database.OpenCollection("failed_rankings", client)
+	if _, err := failedCollection.DeleteOne(ctx, bson.M{"imdb_id": imdbId}); err != nil {
+		logger.Error("error removing failed ranking", "imdb_id", imdbId, "error", err)
+	}
+}
+
+// rankingRetryResult 单条重试结果
+type rankingRetryResult struct {
+	ImdbID       string `json:"imdb_id"`
+	Status       string `json:"status"`
+	RankingName  string `json:"ranking_name,omitempty"`
+	RankingValue int    `json:"ranking_value,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RetryFailedRankings 重新处理失败 AI 排名的处理器函数（仅管理员）
+// 按最早失败的顺序取出 failed_rankings 中最多 maxRankingRetryBatch 条，逐条重新调用 GetReviewRanking：
+// 成功的写入电影的 admin_review 和 ranking 并从队列中移除；仍然失败的保留并累加 attempts；
+// 电影已删除或已锁定的直接移除（skipped）。LLM_DISABLED=true 时返回 503
+// 一次 AI 调用在重试耗尽前可能花费数十秒（见 llmCallBudget），剩余时间不足以完整处理下一条时提前结束，
+// 未处理的条目留在队列中，数量通过 deferred 返回，可以再次调用继续处理
+func RetryFailedRankings(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		if llmDisabled() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI ranking is disabled"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, rankingRetryTimeout())
+		defer cancel()
+		var failedCollection *mongo.Collection = database.OpenCollection("failed_rankings", client)
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		var entries []models.FailedRanking
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "last_attempt_at", Value: 1}}).
+			SetLimit(maxRankingRetryBatch)
+		if err := database.FindAll(ctx, failedCollection, bson.M{}, &entries, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching failed rankings"})
+			return
+		}
+
+		actorId, _ := utils.GetUserIdFromContext(c)
+		results := make([]rankingRetryResult, 0, len(entries))
+		summary := map[string]int{retryStatusRanked: 0, retryStatusFailed: 0, retryStatusSkipped: 0}
+		entryBudget := llmCallBudget() + rankingRetryWriteMargin
+		for _, entry := range entries {
+			// 第一条总会处理（总时间按至少一条计算），之后剩余时间不足一条时停止
+			if deadline, ok := ctx.Deadline(); ok && len(results) > 0 && time.Until(deadline) < entryBudget {
+				break
+			}
+			result := retryFailedRanking(ctx, c, client, movieCollection, entry, actorId)
+			summary[result.Status]++
+			results = append(results, result)
+		}
+
+		remaining, _, err := database.Count(ctx, failedCollection, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting failed rankings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"processed": len(results),
+			"deferred":  len(entries) - len(results),
+			"summary":   summary,
+			"remaining": remaining,
+			"results":   results,
+		})
+	}
+}
+
+// retryFailedRanking 重新处理一条失败的排名
+func retryFailedRanking(ctx context.Context, c *gin.Context, client *mongo.Client, movieCollection *mongo.Collection, entry models.FailedRanking, actorId string) rankingRetryResult {
+	result := rankingRetryResult{ImdbID: entry.ImdbID}
+
+	sentiment, rankVal, err := GetReviewRanking(entry.AdminReview, client, c)
+	if err != nil {
+		result.Status = retryStatusFailed
+		result.Error = err.Error()
+		if !errors.Is(err, ErrNoRankings) {
+			recordFailedRanking(ctx, client, entry.ImdbID, entry.AdminReview, entry.ActorID, err)
+		}
+		return result
+	}
+
+	ranking := models.Ranking{RankingValue: rankVal, RankingName: sentiment}
+	update := bson.M{"$set": bson.M{
+		"admin_review": entry.AdminReview,
+		"ranking":      ranking,
+		"updated_at":   time.Now(),
+	}}
+	updated, err := movieCollection.UpdateOne(ctx, unlockedMovieFilter(entry.ImdbID), update)
+	if err != nil {
+		result.Status = retryStatusFailed
+		result.Error = "Error updating movie"
+		return result
+	}
+	if updated.MatchedCount == 0 {
+		// 电影已删除或已锁定，重试不会再成功
+		result.Status = retryStatusSkipped
+		result.Error = "Movie not found or locked"
+		clearFailedRanking(ctx, client, entry.ImdbID)
+		return result
+	}
+
+	invalidateRecommendations()
+	recordAudit(ctx, client, models.AuditActionRank, actorId, entry.ImdbID, bson.M{
+		"ranking_name":  sentiment,
+		"ranking_value": rankVal,
+		"ai_generated":  true,
+		"source":        "retry",
+	})
+	clearFailedRanking(ctx, client, entry.ImdbID)
+
+	result.Status = retryStatusRanked
+	result.RankingName = sentiment
+	result.RankingValue = rankVal
+	return result
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRetryFailedRankingsStopsWhenBudgetRunsOut(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := database.OpenCollection("rankings", client).InsertOne(ctx, models.Ranking{RankingValue: 1, RankingName: "Excellent"}); err != nil {
+		t.Fatalf("insert ranking: %v", err)
+	}
+	movies := database.OpenCollection("movies", client)
+	failed := database.OpenCollection("failed_rankings", client)
+	for i, imdbId := range []string{"tt1", "tt2", "tt3"} {
+		if _, err := movies.InsertOne(ctx, models.Movie{ImdbID: imdbId, Title: "Queued"}); err != nil {
+			t.Fatalf("insert movie: %v", err)
+		}
+		entry := models.FailedRanking{ImdbID: imdbId, AdminReview: "Great", LastAttemptAt: time.Now().Add(time.Duration(i) * time.Second)}
+		if _, err := failed.InsertOne(ctx, entry); err != nil {
+			t.Fatalf("insert failed ranking: %v", err)
+		}
+	}
+
+	// 单次 AI 调用最长约 2 分钟，请求的总时间只够完整处理一条
+	t.Setenv("LLM_MAX_ATTEMPTS", "3")
+	t.Setenv("LLM_TIMEOUT_SECONDS", "40")
+	useStubRanker(t, &stubRanker{response: "Excellent"})
+
+	router := gin.New()
+	router.POST("/admin/rankings/retry-failed", withIdentity("admin-1", models.RoleAdmin), RetryFailedRankings(client))
+	w := performJSON(router, http.MethodPost, "/admin/rankings/retry-failed", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Processed int   `json:"processed"`
+		Deferred  int   `json:"deferred"`
+		Remaining int64 `json:"remaining"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Processed != 1 || body.Deferred != 2 || body.Remaining != 2 {
+		t.Errorf("processed = %d, deferred = %d, remaining = %d; want 1, 2, 2", body.Processed, body.Deferred, body.Remaining)
+	}
+}
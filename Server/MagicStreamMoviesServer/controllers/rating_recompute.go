@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// 重算任务状态
+const (
+	recomputeStatusRunning   = "running"
+	recomputeStatusCompleted = "completed"
+	recomputeStatusFailed    = "failed"
+)
+
+// recomputeBatchSize 每次 BulkWrite 写入的电影数
+const recomputeBatchSize = 500
+
+// ratingRecomputeJob 评分聚合重算任务的状态
+type ratingRecomputeJob struct {
+	ID            string     `json:"id"`
+	Status        string     `json:"status"`
+	DryRun        bool       `json:"dry_run"`
+	StartedBy     string     `json:"started_by"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	MoviesChecked int        `json:"movies_checked"`
+	MoviesDrifted int        `json:"movies_drifted"`
+	MoviesUpdated int        `json:"movies_updated"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// 同一时间只运行一个重算任务，只保留最近一次任务的状态（进程内，重启后丢失）
+var (
+	recomputeMu  sync.Mutex
+	recomputeJob *ratingRecomputeJob
+)
+
+// StartRatingRecompute 启动评分聚合重算任务的处理器函数（仅管理员）
+// 按 imdb_id 聚合 reviews 集合，与电影上的 user_rating_count、user_rating_sum、user_rating_avg 比较，
+// 不一致的电影批量覆盖为实际值；传入 dry_run=true 时只统计不一致的电影数量，不写入。
+// 任务在后台执行，立即返回 202 和任务状态，通过 GET /admin/ratings/recompute 查询进度；已有任务在运行时返回 409
+func StartRatingRecompute(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		dryRun, ok := parseBoolQuery(c, "dry_run", false)
+		if !ok {
+			return
+		}
+
+		recomputeMu.Lock()
+		if recomputeJob != nil && recomputeJob.Status == recomputeStatusRunning {
+			job := *recomputeJob
+			recomputeMu.Unlock()
+			c.JSON(http.StatusConflict, gin.H{"error": "A recompute job is already running", "job": job})
+			return
+		}
+		actorId, _ := utils.GetUserIdFromContext(c)
+		job := &ratingRecomputeJob{
+			ID:        bson.NewObjectID().Hex(),
+			Status:    recomputeStatusRunning,
+			DryRun:    dryRun,
+			StartedBy: actorId,
+			StartedAt: time.Now(),
+		}
+		recomputeJob = job
+		snapshot := *job
+		recomputeMu.Unlock()
+
+		// 后台任务不使用请求的上下文，请求返回后继续执行
+		go runRatingRecompute(client, job)
+
+		c.JSON(http.StatusAccepted, snapshot)
+	}
+}
+
+// GetRatingRecomputeStatus 查询最近一次评分聚合重算任务状态的处理器函数（仅管理员）
+func GetRatingRecomputeStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		recomputeMu.Lock()
+		defer recomputeMu.Unlock()
+		if recomputeJob == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No recompute job has been started"})
+			return
+		}
+		c.JSON(http.StatusOK, *recomputeJob)
+	}
+}
+
+// updateRecomputeJob 在锁内修改任务状态
+func updateRecomputeJob(job *ratingRecomputeJob, update func(job *ratingRecomputeJob)) {
+	recomputeMu.Lock()
+	defer recomputeMu.Unlock()
+	update(job)
+}
+
+// runRatingRecompute 执行重算任务并记录结果
+func runRatingRecompute(client *mongo.Client, job *ratingRecomputeJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	err := recomputeRatings(ctx, client, job)
+
+	updateRecomputeJob(job, func(job *ratingRecomputeJob) {
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		job.Status = recomputeStatusCompleted
+		if err != nil {
+			job.Status = recomputeStatusFailed
+			job.Error = err.Error()
+		}
+	})
+	if err != nil {
+		logger.Error("rating recompute failed", "job_id", job.ID, "error", err)
+		return
+	}
+	logger.Info("rating recompute finished", "job_id", job.ID, "dry_run", job.DryRun,
+		"checked", job.MoviesChecked, "drifted", job.MoviesDrifted, "updated", job.MoviesUpdated)
+}
+
+// recomputeRatings 比较每部电影的聚合评分与 reviews 集合的实际值，非试运行时批量修正
+// 重算期间提交的评论可能被覆盖，建议在低峰期执行，之后再试运行一次确认没有偏差
+func recomputeRatings(ctx context.Context, client *mongo.Client, job *ratingRecomputeJob) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$imdb_id",
+			"count": bson.M{"$sum": 1},
+			"sum":   bson.M{"$sum": "$rating"},
+		}}},
+	}
+	var totals []struct {
+		ImdbID string `bson:"_id"`
+		Count  int    `bson:"count"`
+		Sum    int    `bson:"sum"`
+	}
+	var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+	if err := database.AggregateAll(ctx, reviewCollection, pipeline, &totals); err != nil {
+		return err
+	}
+	type ratingTotal struct{ count, sum int }
+	actual := make(map[string]ratingTotal, len(totals))
+	for _, total := range totals {
+		actual[total.ImdbID] = ratingTotal{count: total.Count, sum: total.Sum}
+	}
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+	cursor, err := movieCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"imdb_id":           1,
+		"user_rating_count": 1,
+		"user_rating_sum":   1,
+		"user_rating_avg":   1,
+	}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var writes []mongo.WriteModel
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		result, err := movieCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		updateRecomputeJob(job, func(job *ratingRecomputeJob) { job.MoviesUpdated += int(result.MatchedCount) })
+		invalidateRecommendations()
+		writes = writes[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var movie struct {
+			ImdbID          string  `bson:"imdb_id"`
+			UserRatingCount int     `bson:"user_rating_count"`
+			UserRatingSum   int     `bson:"user_rating_sum"`
+			UserRatingAvg   float64 `bson:"user_rating_avg"`
+		}
+		if err := cursor.Decode(&movie); err != nil {
+			return err
+		}
+
+		expected := actual[movie.ImdbID]
+		expectedAvg := 0.0
+		if expected.count > 0 {
+			expectedAvg = float64(expected.sum) / float64(expected.count)
+		}
+		drifted := movie.UserRatingCount != expected.count ||
+			movie.UserRatingSum != expected.sum ||
+			math.Abs(movie.UserRatingAvg-expectedAvg) > 1e-9
+		updateRecomputeJob(job, func(job *ratingRecomputeJob) {
+			job.MoviesChecked++
+			if drifted {
+				job.MoviesDrifted++
+			}
+		})
+		if !drifted || job.DryRun {
+			continue
+		}
+
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"imdb_id": movie.ImdbID}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"user_rating_count": expected.count,
+				"user_rating_sum":   expected.sum,
+				"user_rating_avg":   expectedAvg,
+			}}))
+		if len(writes) >= recomputeBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	dramaGenre  = models.Genre{GenreID: 1, GenreName: "Drama"}
+	comedyGenre = models.Genre{GenreID: 2, GenreName: "Comedy"}
+)
+
+// seedCatalog 写入测试用的类型和电影：排名值越小排名越高，tt9 未排名
+func seedCatalog(t *testing.T, client *mongo.Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	genres := database.OpenCollection("genres", client)
+	for _, genre := range []models.Genre{dramaGenre, comedyGenre} {
+		if _, err := genres.InsertOne(ctx, genre); err != nil {
+			t.Fatalf("insert genre: %v", err)
+		}
+	}
+
+	movies := []models.Movie{
+		{ImdbID: "tt1", Title: "Drama One", Genre: []models.Genre{dramaGenre}, Ranking: models.Ranking{RankingValue: 1, RankingName: "Excellent"}},
+		{ImdbID: "tt2", Title: "Comedy Two", Genre: []models.Genre{comedyGenre}, Ranking: models.Ranking{RankingValue: 2, RankingName: "Good"}},
+		{ImdbID: "tt3", Title: "Drama Three", Genre: []models.Genre{dramaGenre}, Ranking: models.Ranking{RankingValue: 3, RankingName: "Okay"}},
+		{ImdbID: "tt4", Title: "Comedy Four", Genre: []models.Genre{comedyGenre}, Ranking: models.Ranking{RankingValue: 4, RankingName: "Bad"}},
+		{ImdbID: "tt9", Title: "Unranked", Genre: []models.Genre{dramaGenre}, Ranking: models.Ranking{RankingValue: models.DefaultUnrankedValue, RankingName: "Not_Ranked"}},
+	}
+	movieCollection := database.OpenCollection("movies", client)
+	for _, movie := range movies {
+		movie.CreatedAt, movie.UpdatedAt = time.Now(), time.Now()
+		if _, err := movieCollection.InsertOne(ctx, movie); err != nil {
+			t.Fatalf("insert movie: %v", err)
+		}
+	}
+}
+
+// recommendedIds 以指定用户身份请求推荐，返回推荐电影的 imdb_id
+func recommendedIds(t *testing.T, client *mongo.Client, userId, query string) []string {
+	t.Helper()
+	router := gin.New()
+	router.GET("/recommendedmovies", withIdentity(userId, models.RoleUser), GetRecommendedMovies(client))
+
+	ids, _, _ := fetchRecommendations(t, router, query)
+	return ids
+}
+
+// fetchRecommendations 通过 router 请求 /recommendedmovies，返回推荐电影的 imdb_id、generated_at 和 X-Cache 响应头
+func fetchRecommendations(t *testing.T, router *gin.Engine, query string) ([]string, time.Time, string) {
+	t.Helper()
+	w := performJSON(router, http.MethodGet, "/recommendedmovies"+query, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /recommendedmovies status = %d; body %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Movies      []models.Movie `json:"movies"`
+		GeneratedAt time.Time      `json:"generated_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode recommendations: %v; body %s", err, w.Body.String())
+	}
+	if body.GeneratedAt.IsZero() {
+		t.Errorf("generated_at missing from body %s", w.Body.String())
+	}
+	ids := make([]string, 0, len(body.Movies))
+	for _, movie := range body.Movies {
+		ids = append(ids, movie.ImdbID)
+	}
+	return ids, body.GeneratedAt, w.Header().Get("X-Cache")
+}
+
+func TestRecommendationsFallBackToTopRankedWithoutGenres(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+
+	router := gin.New()
+	router.POST("/register", RegisterUser(client))
+	w := performJSON(router, http.MethodPost, "/register", gin.H{
+		"first_name":       "Newcomer",
+		"last_name":        "Example",
+		"email":            "new@example.com",
+		"password":         "password123",
+		"favourite_genres": []models.Genre{},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var user models.User
+	if err := database.OpenCollection("users", client).FindOne(ctx, bson.M{"email": "new@example.com"}).Decode(&user); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+
+	// 没有喜爱类型时退回全站排名最高的电影，并遵守 limit
+	if got, want := recommendedIds(t, client, user.UserID, "?limit=3"), []string{"tt1", "tt2", "tt3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recommendations = %v, want %v", got, want)
+	}
+	if got, want := recommendedIds(t, client, user.UserID, "?limit=50&include_unranked=false"), []string{"tt1", "tt2", "tt3", "tt4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recommendations without unranked = %v, want %v", got, want)
+	}
+}
+
+func TestUpdatingFavouriteGenresShiftsRecommendations(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+	userId := createTestUser(t, client, "erin@example.com", "password123", comedyGenre)
+
+	if got, want := recommendedIds(t, client, userId, ""), []string{"tt2", "tt4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recommendations before update = %v, want %v", got, want)
+	}
+
+	router := gin.New()
+	router.PATCH("/me/genres", withIdentity(userId, models.RoleUser), UpdateFavouriteGenres(client))
+
+	w := performJSON(router, http.MethodPatch, "/me/genres", gin.H{"favourite_genres": []gin.H{{"genre_id": 3, "genre_name": "Western"}}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown genre status = %d, want 400; body %s", w.Code, w.Body.String())
+	}
+
+	w = performJSON(router, http.MethodPatch, "/me/genres", gin.H{"favourite_genres": []gin.H{{"genre_id": 1, "genre_name": "drama"}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update genres status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	// 推荐缓存以喜爱类型为键，修改后立即得到新类型的推荐
+	if got, want := recommendedIds(t, client, userId, ""), []string{"tt1", "tt3", "tt9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recommendations after update = %v, want %v", got, want)
+	}
+}
+
+func TestMovieWritesInvalidateCachedRecommendations(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+	userId := createTestUser(t, client, "frank@example.com", "password123", dramaGenre)
+
+	router := gin.New()
+	router.GET("/recommendedmovies", withIdentity(userId, models.RoleUser), GetRecommendedMovies(client))
+	router.DELETE("/movie/:imdb_id", withIdentity("admin-1", models.RoleAdmin), DeleteMovie(client))
+
+	first, generatedAt, _ := fetchRecommendations(t, router, "")
+	if want := []string{"tt1", "tt3", "tt9"}; !reflect.DeepEqual(first, want) {
+		t.Fatalf("recommendations = %v, want %v", first, want)
+	}
+	cached, cachedAt, cacheStatus := fetchRecommendations(t, router, "")
+	if cacheStatus != "HIT" || !cachedAt.Equal(generatedAt) || !reflect.DeepEqual(cached, first) {
+		t.Fatalf("second request X-Cache = %q, generated_at %v (first %v), ids %v; want a cache hit", cacheStatus, cachedAt, generatedAt, cached)
+	}
+
+	if w := performJSON(router, http.MethodDelete, "/movie/tt1", nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /movie/tt1 status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	after, _, cacheStatus := fetchRecommendations(t, router, "")
+	if cacheStatus != "MISS" {
+		t.Errorf("X-Cache after delete = %q, want MISS", cacheStatus)
+	}
+	if want := []string{"tt3", "tt9"}; !reflect.DeepEqual(after, want) {
+		t.Errorf("recommendations after delete = %v, want %v", after, want)
+	}
+}
+
+func TestInvalidateRecommendationsChangesCacheGeneration(t *testing.T) {
+	before := recommendationsGeneration.Load()
+	invalidateRecommendations()
+	if after := recommendationsGeneration.Load(); after == before {
+		t.Errorf("generation = %d after invalidate, want it to change from %d", after, before)
+	}
+}
+
+func TestPreviewUserRecommendationsMatchesUserResponse(t *testing.T) {
+	client := testClient(t)
+	seedCatalog(t, client)
+	userId := createTestUser(t, client, "preview@example.com", "password123", dramaGenre)
+
+	router := gin.New()
+	router.GET("/admin/users/:id/recommendations", withIdentity("admin-1", models.RoleAdmin), PreviewUserRecommendations(client))
+	w := performJSON(router, http.MethodGet, "/admin/users/"+userId+"/recommendations", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode preview: %v; body %s", err, w.Body.String())
+	}
+	var movies []models.Movie
+	if err := json.Unmarshal(body["movies"], &movies); err != nil {
+		t.Fatalf("preview movies is not a movie list: %v; body %s", err, w.Body.String())
+	}
+	var generatedAt time.Time
+	if err := json.Unmarshal(body["generated_at"], &generatedAt); err != nil || generatedAt.IsZero() {
+		t.Errorf("preview generated_at = %s, want a timestamp", body["generated_at"])
+	}
+	if w.Header().Get("X-Generated-At") == "" {
+		t.Error("preview has no X-Generated-At header")
+	}
+
+	// 与用户自己请求得到的推荐相同
+	previewIds := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		previewIds = append(previewIds, movie.ImdbID)
+	}
+	if userIds := recommendedIds(t, client, userId, ""); !reflect.DeepEqual(previewIds, userIds) {
+		t.Errorf("preview = %v, user sees %v", previewIds, userIds)
+	}
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// SubmitUserReview 用户提交或更新电影评论的处理器函数
+// 每个用户对每部电影只有一条评论（以 user_id + imdb_id 唯一确定），重复提交会更新原评论，
+// 因此客户端重试是安全的。评分发生变化时会追加到 rating_history（保留最近 N 条），
+// 当前评分始终是电影聚合评分的依据。
+func SubmitUserReview(client *mongo.Client) gin.HandlerFunc {
+	// 评分历史保留条数，默认 10 条
+	historyLimit := 10
+	if value, err := strconv.Atoi(os.Getenv("REVIEW_RATING_HISTORY_LIMIT")); err == nil && value > 0 {
+		historyLimit = value
+	}
+
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var req struct {
+			Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+			Comment string `json:"comment" validate:"max=2000"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+
+		// 确认电影存在
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		// 使用聚合管道式更新，在一次原子操作中完成：
+		// - 写入最新评分和评论
+		// - 仅当评分发生变化时追加历史记录，并截断到最近 historyLimit 条
+		// - 首次插入时记录 created_at
+		now := time.Now()
+		existingHistory := bson.M{"$ifNull": bson.A{"$rating_history", bson.A{}}}
+		update := bson.A{
+			bson.M{"$set": bson.M{
+				"rating_history": bson.M{"$cond": bson.A{
+					bson.M{"$ne": bson.A{"$rating", req.Rating}},
+					bson.M{"$slice": bson.A{
+						bson.M{"$concatArrays": bson.A{
+							existingHistory,
+							bson.A{bson.M{"rating": req.Rating, "changed_at": now}},
+						}},
+						-historyLimit,
+					}},
+					existingHistory,
+				}},
+				"rating":     req.Rating,
+				"comment":    req.Comment,
+				"updated_at": now,
+				"created_at": bson.M{"$ifNull": bson.A{"$created_at", now}},
+			}},
+		}
+		filter := bson.M{"user_id": userId, "imdb_id": movieId}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+		// 返回更新前的文档，用于计算聚合评分的增量
+		var previous models.Review
+		created := false
+		err = reviewCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving review"})
+				return
+			}
+			created = true
+		}
+
+		// 根据新旧评分更新电影的聚合评分
+		countDelta, sumDelta := 0, req.Rating-previous.Rating
+		if created {
+			countDelta = 1
+		}
+		if err := applyRatingDelta(ctx, movieCollection, movieId, countDelta, sumDelta); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie rating"})
+			return
+		}
+
+		var review models.Review
+		if err := database.FindOne(ctx, reviewCollection, filter, &review); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching review"})
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, review)
+	}
+}
+
+// applyRatingDelta 以原子操作调整电影的评分数量和评分总和，并重新计算平均分
+// 使用聚合管道式更新，避免在 Go 中读取-修改-写回导致并发请求丢失更新
+func applyRatingDelta(ctx context.Context, movieCollection *mongo.Collection, imdbId string, countDelta, sumDelta int) error {
+	if countDelta == 0 && sumDelta == 0 {
+		return nil
+	}
+
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"user_rating_count": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$user_rating_count", 0}}, countDelta}},
+			"user_rating_sum":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$user_rating_sum", 0}}, sumDelta}},
+		}},
+		bson.M{"$set": bson.M{
+			"user_rating_avg": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$user_rating_count", 0}},
+				bson.M{"$divide": bson.A{"$user_rating_sum", "$user_rating_count"}},
+				0,
+			}},
+		}},
+	}
+
+	_, err := movieCollection.UpdateOne(ctx, bson.M{"imdb_id": imdbId}, update)
+	return err
+}
+
+// ratingBucket 评分分布中的一个档位
+type ratingBucket struct {
+	Rating int   `json:"rating"`
+	Count  int64 `json:"count"`
+}
+
+// GetReviewHistogram 获取单部电影评分分布的处理器函数
+// 按评分值统计 reviews 集合中该电影的评论数量，始终返回 1 到 5 星全部档位（没有评论的档位计数为 0），
+// 用于电影详情页的星级分布条形图，与电影上的平均分 user_rating_avg 互为补充
+func GetReviewHistogram(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"imdb_id": movieId}}},
+			{{Key: "$group", Value: bson.M{"_id": "$rating", "count": bson.M{"$sum": 1}}}},
+		}
+		var groups []struct {
+			Rating int   `bson:"_id"`
+			Count  int64 `bson:"count"`
+		}
+		if err := database.AggregateAll(ctx, reviewCollection, pipeline, &groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating reviews"})
+			return
+		}
+
+		// 先填充全部档位，再写入聚合结果；超出 1-5 的历史脏数据忽略
+		buckets := make([]ratingBucket, 5)
+		for i := range buckets {
+			buckets[i].Rating = i + 1
+		}
+		var total int64
+		for _, group := range groups {
+			if group.Rating < 1 || group.Rating > 5 {
+				continue
+			}
+			buckets[group.Rating-1].Count = group.Count
+			total += group.Count
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"imdb_id": movieId,
+			"total":   total,
+			"buckets": buckets,
+		})
+	}
+}
+
+// reviewSorts 评论列表支持的排序方式及其排序条件，最后以 _id 兜底保证分页顺序稳定
+var reviewSorts = map[string]bson.D{
+	"recent":  {{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+	"helpful": {{Key: "helpful_count", Value: -1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+}
+
+// GetMovieReviews 获取电影评论列表的处理器函数
+// sort 为 recent（默认，最近更新的在前）或 helpful（有用票数最多的在前，同票数按更新时间倒序），
+// 两种方式都按 page（默认 1）和 limit（默认 20，最大 100）分页
+func GetMovieReviews(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		sortName := c.DefaultQuery("sort", "recent")
+		sortSpec, ok := reviewSorts[sortName]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Unsupported sort, expected recent or helpful",
+				"code":    "UNSUPPORTED_SORT",
+				"allowed": []string{"recent", "helpful"},
+			})
+			return
+		}
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+
+		filter := bson.M{"imdb_id": movieId}
+		total, _, err := database.Count(ctx, reviewCollection, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting reviews"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(sortSpec).
+			SetProjection(bson.M{"helpful_voters": 0}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+		reviews := []models.Review{}
+		if err := database.FindAll(ctx, reviewCollection, filter, &reviews, findOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"reviews": reviews,
+			"sort":    sortName,
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+		})
+	}
+}
+
+// MarkReviewHelpful 将评论标记为"有用"的处理器函数
+// 每个用户对每条评论只能投一票，不能给自己的评论投票；返回评论当前的有用票数。
+// 投票检查和计数在一次原子更新中完成，并发重复提交不会重复计数
+func MarkReviewHelpful(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		reviewId, err := bson.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review id"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+		var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+
+		var review models.Review
+		err = reviewCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": reviewId, "user_id": bson.M{"$ne": userId}, "helpful_voters": bson.M{"$ne": userId}},
+			bson.M{"$addToSet": bson.M{"helpful_voters": userId}, "$inc": bson.M{"helpful_count": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&review)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"review_id": reviewId, "helpful_count": review.HelpfulCount})
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating review"})
+			return
+		}
+
+		// 没有匹配时区分评论不存在、自己的评论和已经投过票
+		if err := database.FindOne(ctx, reviewCollection, bson.M{"_id": reviewId}, &review); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching review"})
+			return
+		}
+		if review.UserID == userId {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot mark your own review as helpful"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Review already marked as helpful", "helpful_count": review.HelpfulCount})
+	}
+}
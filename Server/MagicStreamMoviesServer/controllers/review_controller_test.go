@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConcurrentRatingDeltasAreExact(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	movies := database.OpenCollection("movies", client)
+	if _, err := movies.InsertOne(ctx, models.Movie{ImdbID: "tt1", Title: "Counter"}); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+
+	const workers = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	wantSum := 0
+	for i := 0; i < workers; i++ {
+		rating := i%5 + 1
+		wantSum += rating
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- applyRatingDelta(ctx, movies, "tt1", 1, rating)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("applyRatingDelta: %v", err)
+		}
+	}
+
+	var movie models.Movie
+	if err := movies.FindOne(ctx, bson.M{"imdb_id": "tt1"}).Decode(&movie); err != nil {
+		t.Fatalf("find movie: %v", err)
+	}
+	if movie.UserRatingCount != workers || movie.UserRatingSum != wantSum {
+		t.Errorf("count = %d, sum = %d; want %d, %d", movie.UserRatingCount, movie.UserRatingSum, workers, wantSum)
+	}
+	if want := float64(wantSum) / workers; movie.UserRatingAvg != want {
+		t.Errorf("avg = %v, want %v", movie.UserRatingAvg, want)
+	}
+}
+
+func TestConcurrentHelpfulVotesAreExact(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reviews := database.OpenCollection("reviews", client)
+	result, err := reviews.InsertOne(ctx, models.Review{UserID: "author", ImdbID: "tt1", Rating: 4, RatingHistory: []models.RatingChange{}})
+	if err != nil {
+		t.Fatalf("insert review: %v", err)
+	}
+	reviewId := result.InsertedID.(bson.ObjectID).Hex()
+
+	// 每个投票者提交两次，重复提交不能重复计数
+	const voters = 50
+	var wg sync.WaitGroup
+	for i := 0; i < voters*2; i++ {
+		voter := fmt.Sprintf("voter-%d", i%voters)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			router := gin.New()
+			router.POST("/review/:id/helpful", withIdentity(voter, models.RoleUser), MarkReviewHelpful(client))
+			w := performJSON(router, http.MethodPost, "/review/"+reviewId+"/helpful", nil)
+			if w.Code != http.StatusOK && w.Code != http.StatusConflict {
+				t.Errorf("vote by %s status = %d; body %s", voter, w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	var review models.Review
+	if err := reviews.FindOne(ctx, bson.M{"_id": result.InsertedID}).Decode(&review); err != nil {
+		t.Fatalf("find review: %v", err)
+	}
+	if review.HelpfulCount != voters || len(review.HelpfulVoters) != voters {
+		t.Errorf("helpful_count = %d, voters = %d; want %d", review.HelpfulCount, len(review.HelpfulVoters), voters)
+	}
+}
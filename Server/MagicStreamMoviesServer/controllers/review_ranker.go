@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// ReviewRanker 根据评论内容从给定的排名等级名称中选择一个
+// 默认实现调用 OpenAI 兼容接口（DeepSeek），测试中可以通过 SetReviewRanker 替换为桩实现
+type ReviewRanker interface {
+	Rank(ctx context.Context, review string, rankingNames []string) (string, error)
+}
+
+// 默认的 AI 接口地址和模型
+const (
+	defaultLLMBaseURL = "https://api.deepseek.com"
+	defaultLLMModel   = "deepseek-chat"
+)
+
+// llmConfig AI 接口的配置，全部来自环境变量
+// LLM_BASE_URL、LLM_MODEL 可以指向 OpenAI、本地 Ollama（http://localhost:11434/v1）等任意 OpenAI 兼容接口；
+// LLM_API_TYPE=azure 时使用 Azure OpenAI，需同时设置 LLM_API_VERSION
+type llmConfig struct {
+	BaseURL    string
+	Model      string
+	APIKey     string
+	APIType    string
+	APIVersion string
+}
+
+// llmConfigFromEnv 读取 AI 接口配置，LLM_API_KEY 未设置时兼容旧的 DEEPSEEK_API_KEY
+func llmConfigFromEnv() llmConfig {
+	cfg := llmConfig{
+		BaseURL:    strings.TrimSpace(os.Getenv("LLM_BASE_URL")),
+		Model:      strings.TrimSpace(os.Getenv("LLM_MODEL")),
+		APIKey:     os.Getenv("LLM_API_KEY"),
+		APIType:    strings.ToLower(strings.TrimSpace(os.Getenv("LLM_API_TYPE"))),
+		APIVersion: strings.TrimSpace(os.Getenv("LLM_API_VERSION")),
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultLLMBaseURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultLLMModel
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("DEEPSEEK_API_KEY")
+	}
+	return cfg
+}
+
+// openAIRanker 基于 OpenAI 兼容接口的 ReviewRanker，提示词模板来自环境变量 BASE_PROMPT_TEMPLATE，
+// 其中的 {rankings} 替换为逗号分隔的排名等级名称，评论内容追加在模板之后
+type openAIRanker struct {
+	llm *openai.LLM
+	err error // 创建客户端失败（例如未设置 API 密钥）时每次 Rank 都返回该错误
+}
+
+// NewReviewRanker 根据环境变量创建默认的 ReviewRanker
+func NewReviewRanker() ReviewRanker {
+	cfg := llmConfigFromEnv()
+	if cfg.APIKey == "" {
+		return &openAIRanker{err: errors.New("LLM_API_KEY (or DEEPSEEK_API_KEY) is not set")}
+	}
+
+	opts := []openai.Option{
+		openai.WithToken(cfg.APIKey),
+		openai.WithBaseURL(cfg.BaseURL),
+		openai.WithModel(cfg.Model),
+	}
+	if cfg.APIType == "azure" {
+		opts = append(opts, openai.WithAPIType(openai.APITypeAzure), openai.WithAPIVersion(cfg.APIVersion))
+	}
+	llm, err := openai.New(opts...)
+	return &openAIRanker{llm: llm, err: err}
+}
+
+func (r *openAIRanker) Rank(ctx context.Context, review string, rankingNames []string) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	prompt := strings.Replace(os.Getenv("BASE_PROMPT_TEMPLATE"), "{rankings}", strings.Join(rankingNames, ","), 1)
+	return r.llm.Call(ctx, prompt+review)
+}
+
+// reviewRanker 当前使用的 ReviewRanker，首次使用时按环境变量创建
+var (
+	reviewRankerMu sync.Mutex
+	reviewRanker   ReviewRanker
+)
+
+// SetReviewRanker 替换排名使用的 ReviewRanker，传入 nil 时恢复为按环境变量创建的默认实现
+func SetReviewRanker(ranker ReviewRanker) {
+	reviewRankerMu.Lock()
+	defer reviewRankerMu.Unlock()
+	reviewRanker = ranker
+}
+
+// currentReviewRanker 返回当前的 ReviewRanker
+func currentReviewRanker() ReviewRanker {
+	reviewRankerMu.Lock()
+	defer reviewRankerMu.Unlock()
+	if reviewRanker == nil {
+		reviewRanker = NewReviewRanker()
+	}
+	return reviewRanker
+}
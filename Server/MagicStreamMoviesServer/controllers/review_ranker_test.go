@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/gin-gonic/gin"
+)
+
+// stubRanker 返回固定结果的 ReviewRanker，并记录收到的参数
+type stubRanker struct {
+	mu           sync.Mutex
+	response     string
+	err          error
+	calls        int
+	review       string
+	rankingNames []string
+}
+
+func (s *stubRanker) Rank(ctx context.Context, review string, rankingNames []string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	s.review = review
+	s.rankingNames = rankingNames
+	return s.response, s.err
+}
+
+// useStubRanker 在测试期间替换 ReviewRanker
+func useStubRanker(t *testing.T, ranker ReviewRanker) {
+	t.Helper()
+	SetReviewRanker(ranker)
+	t.Cleanup(func() { SetReviewRanker(nil) })
+}
+
+func TestRankReviewUsesConfiguredRanker(t *testing.T) {
+	stub := &stubRanker{response: "Good"}
+	useStubRanker(t, stub)
+
+	response, err := rankReview("A solid film", []string{"Excellent", "Good"})
+	if err != nil || response != "Good" {
+		t.Fatalf("rankReview = %q, %v; want Good, nil", response, err)
+	}
+	if stub.calls != 1 || stub.review != "A solid film" || !reflect.DeepEqual(stub.rankingNames, []string{"Excellent", "Good"}) {
+		t.Errorf("ranker called %d times with review %q and names %v", stub.calls, stub.review, stub.rankingNames)
+	}
+}
+
+func TestRankReviewReturnsPermanentRankerError(t *testing.T) {
+	failure := errors.New("invalid api key")
+	stub := &stubRanker{err: failure}
+	useStubRanker(t, stub)
+
+	if _, err := rankReview("A solid film", []string{"Good"}); !errors.Is(err, failure) {
+		t.Fatalf("rankReview error = %v, want %v", err, failure)
+	}
+	if stub.calls != 1 {
+		t.Errorf("ranker called %d times, want 1", stub.calls)
+	}
+}
+
+func TestGetReviewRankingWithStubRanker(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rankings := database.OpenCollection("rankings", client)
+	for _, ranking := range []models.Ranking{
+		{RankingValue: 1, RankingName: "Excellent"},
+		{RankingValue: 2, RankingName: "Good"},
+		{RankingValue: models.DefaultUnrankedValue, RankingName: "Not_Ranked"},
+	} {
+		if _, err := rankings.InsertOne(ctx, ranking); err != nil {
+			t.Fatalf("insert ranking: %v", err)
+		}
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/updatereview/tt1", nil)
+
+	stub := &stubRanker{response: " 'Excellent.' "}
+	useStubRanker(t, stub)
+	name, value, err := GetReviewRanking("Stunning from start to finish", client, c)
+	if err != nil || name != "Excellent" || value != 1 {
+		t.Fatalf("GetReviewRanking = %q, %d, %v; want Excellent, 1, nil", name, value, err)
+	}
+	if !reflect.DeepEqual(stub.rankingNames, []string{"Excellent", "Good"}) {
+		t.Errorf("ranking names offered = %v, want the two ranked levels without the sentinel", stub.rankingNames)
+	}
+
+	useStubRanker(t, &stubRanker{response: "Mediocre"})
+	if _, _, err := GetReviewRanking("Something else entirely", client, c); !errors.Is(err, ErrUnrecognizedRanking) {
+		t.Errorf("GetReviewRanking error = %v, want ErrUnrecognizedRanking", err)
+	}
+}
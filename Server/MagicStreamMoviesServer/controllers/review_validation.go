@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReviewValidationBatch 单次预检的最大评论数
+const maxReviewValidationBatch = 500
+
+// promptInjectionMarkers 常见的提示词注入片段（小写），评论中出现时 AI 很可能不返回合法的排名名称
+var promptInjectionMarkers = []string{
+	"ignore previous",
+	"ignore all previous",
+	"ignore the above",
+	"disregard previous",
+	"disregard the above",
+	"system prompt",
+	"you are now",
+	"new instructions",
+	"respond with",
+	"reply with",
+	"{rankings}",
+	"```",
+}
+
+// maxAdminReviewLength 返回建议的管理员评论最大字符数，超过时提示词过长且成本更高
+// 优先读取环境变量 MAX_ADMIN_REVIEW_LENGTH，默认 2000
+func maxAdminReviewLength() int {
+	if value, err := strconv.Atoi(os.Getenv("MAX_ADMIN_REVIEW_LENGTH")); err == nil && value > 0 {
+		return value
+	}
+	return 2000
+}
+
+// reviewValidationResult 单条评论的预检结果
+type reviewValidationResult struct {
+	Index            int      `json:"index"`
+	Normalized       string   `json:"normalized"`
+	Length           int      `json:"length"`
+	Empty            bool     `json:"empty"`
+	TooLong          bool     `json:"too_long"`
+	InjectionMarkers []string `json:"injection_markers"`
+	Valid            bool     `json:"valid"`
+}
+
+// validateAdminReview 预检一条评论：合并空白后统计字符数，检查是否为空、是否过长以及是否包含注入片段
+func validateAdminReview(index int, review string, maxLength int) reviewValidationResult {
+	normalized := strings.Join(strings.Fields(review), " ")
+	result := reviewValidationResult{
+		Index:            index,
+		Normalized:       normalized,
+		Length:           utf8.RuneCountInString(normalized),
+		InjectionMarkers: []string{},
+	}
+	result.Empty = result.Length == 0
+	result.TooLong = result.Length > maxLength
+
+	lower := strings.ToLower(normalized)
+	for _, marker := range promptInjectionMarkers {
+		if strings.Contains(lower, marker) {
+			result.InjectionMarkers = append(result.InjectionMarkers, marker)
+		}
+	}
+
+	result.Valid = !result.Empty && !result.TooLong && len(result.InjectionMarkers) == 0
+	return result
+}
+
+// ValidateAdminReviews 批量预检管理员评论的处理器函数（仅管理员）
+// 请求体为 {"reviews": ["...", ...]}，逐条返回规范化后的文本、字符数、是否为空、是否超过 MAX_ADMIN_REVIEW_LENGTH
+// 以及检测到的提示词注入片段，不调用 AI。用于批量重新排名前排除明显无效的输入，避免浪费 AI 调用
+func ValidateAdminReviews() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		var req struct {
+			Reviews []string `json:"reviews" validate:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		if len(req.Reviews) > maxReviewValidationBatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many reviews, at most %d allowed per request", maxReviewValidationBatch)})
+			return
+		}
+
+		maxLength := maxAdminReviewLength()
+		results := make([]reviewValidationResult, 0, len(req.Reviews))
+		valid := 0
+		for i, review := range req.Reviews {
+			result := validateAdminReview(i, review, maxLength)
+			if result.Valid {
+				valid++
+			}
+			results = append(results, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total":      len(results),
+			"valid":      valid,
+			"invalid":    len(results) - valid,
+			"max_length": maxLength,
+			"results":    results,
+		})
+	}
+}
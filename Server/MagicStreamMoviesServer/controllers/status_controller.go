@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/cache"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// 依赖的健康状态
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+	statusDown     = "down"
+)
+
+// startedAt 进程启动时间，用于计算运行时长
+var startedAt = time.Now()
+
+// dependencyStatus 单个依赖的健康状态
+// 该接口无需登录，只返回状态和延迟；失败原因（连接串、主机名、配置缺失等）只写入日志
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs *int64 `json:"latency_ms,omitempty"`
+}
+
+// GetStatus 汇总各依赖健康状态的处理器函数
+// 返回 mongodb（ping 结果和延迟）、llm（可达性，结果缓存 LLM_STATUS_CACHE_SECONDS 秒，默认 60）和运行时长，
+// 以及汇总状态：MongoDB 不可用为 down（返回 503），MongoDB 延迟超过 STATUS_DB_SLOW_MS（默认 500）毫秒或 LLM 不可达为 degraded
+// 依赖检查失败的具体错误记录在日志中，不在响应中返回
+func GetStatus(client *mongo.Client) gin.HandlerFunc {
+	llmTTL := 60 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("LLM_STATUS_CACHE_SECONDS")); err == nil && value > 0 {
+		llmTTL = time.Duration(value) * time.Second
+	}
+	llmCache := cache.New[dependencyStatus](llmTTL, 1)
+
+	slowThreshold := 500 * time.Millisecond
+	if value, err := strconv.Atoi(os.Getenv("STATUS_DB_SLOW_MS")); err == nil && value > 0 {
+		slowThreshold = time.Duration(value) * time.Millisecond
+	}
+
+	return func(c *gin.Context) {
+		mongoStatus := checkMongo(c, client, slowThreshold)
+
+		llmStatus, ok := llmCache.Get("llm")
+		if !ok {
+			llmStatus = checkLLM(c)
+			llmCache.Set("llm", llmStatus)
+		}
+
+		overall := statusOK
+		if mongoStatus.Status == statusDown {
+			overall = statusDown
+		} else if mongoStatus.Status != statusOK || llmStatus.Status != statusOK {
+			overall = statusDegraded
+		}
+
+		httpStatus := http.StatusOK
+		if overall == statusDown {
+			httpStatus = http.StatusServiceUnavailable
+		}
+		c.JSON(httpStatus, gin.H{
+			"status": overall,
+			"dependencies": gin.H{
+				"mongodb": mongoStatus,
+				"llm":     llmStatus,
+			},
+			"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+			"started_at":     startedAt,
+		})
+	}
+}
+
+// checkMongo ping MongoDB 并记录延迟，超过 slowThreshold 视为 degraded
+func checkMongo(c *gin.Context, client *mongo.Client, slowThreshold time.Duration) dependencyStatus {
+	ctx, cancel := context.WithTimeout(c, 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Ping(ctx, nil)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		logger.Error("status check: MongoDB ping failed", "latency_ms", latency, "error", err)
+		return dependencyStatus{Status: statusDown, LatencyMs: &latency}
+	}
+	if time.Since(start) > slowThreshold {
+		return dependencyStatus{Status: statusDegraded, LatencyMs: &latency}
+	}
+	return dependencyStatus{Status: statusOK, LatencyMs: &latency}
+}
+
+// checkLLM 检查配置的 AI 接口是否可达，只要收到 HTTP 响应即视为可达，不消耗调用额度
+// LLM_DISABLED=true 时不探测，视为正常
+func checkLLM(c *gin.Context) dependencyStatus {
+	if llmDisabled() {
+		return dependencyStatus{Status: statusOK}
+	}
+	cfg := llmConfigFromEnv()
+	if cfg.APIKey == "" {
+		logger.Error("status check: LLM_API_KEY (or DEEPSEEK_API_KEY) is not set")
+		return dependencyStatus{Status: statusDown}
+	}
+
+	ctx, cancel := context.WithTimeout(c, 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.BaseURL, nil)
+	if err != nil {
+		logger.Error("status check: invalid LLM base URL", "error", err)
+		return dependencyStatus{Status: statusDown}
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		logger.Error("status check: LLM endpoint unreachable", "latency_ms", latency, "error", err)
+		return dependencyStatus{Status: statusDown, LatencyMs: &latency}
+	}
+	resp.Body.Close()
+	return dependencyStatus{Status: statusOK, LatencyMs: &latency}
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetStatusHidesDependencyErrors(t *testing.T) {
+	t.Setenv("LLM_DISABLED", "false")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.GET("/status", GetStatus(offlineClient(t)))
+
+	// 离线客户端 ping 会一直等到超时，用较短的请求上下文让检查尽快失败
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/status", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503; body %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Status       string                                `json:"status"`
+		Dependencies map[string]map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if body.Status != statusDown {
+		t.Errorf("overall status = %q, want %q", body.Status, statusDown)
+	}
+	for name, dependency := range body.Dependencies {
+		for field := range dependency {
+			if field != "status" && field != "latency_ms" {
+				t.Errorf("dependency %s exposes field %q; body %s", name, field, w.Body.String())
+			}
+		}
+	}
+	for _, leaked := range []string{"127.0.0.1", "API_KEY"} {
+		if strings.Contains(w.Body.String(), leaked) {
+			t.Errorf("status body leaks %q: %s", leaked, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxTagLength 单个标签的最大字符数
+const maxTagLength = 50
+
+// normalizeTag 规范化单个标签：去除首尾空白、转为小写，内部连续空白替换为连字符，
+// 使 "Oscar Winner" 与 "oscar-winner" 视为同一个标签
+func normalizeTag(tag string) (string, error) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+	if normalized == "" {
+		return "", errors.New("tag must not be empty")
+	}
+	if len([]rune(normalized)) > maxTagLength {
+		return "", fmt.Errorf("tag must be at most %d characters", maxTagLength)
+	}
+	return normalized, nil
+}
+
+// normalizeTags 规范化标签列表并去重，保留首次出现的顺序
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		value, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		normalized = append(normalized, value)
+	}
+	return normalized, nil
+}
+
+// AddMovieTags 为电影添加标签的处理器函数（仅管理员）
+// 请求体为 {"tags": [...]}，标签规范化后追加到电影上，已存在的标签不会重复添加
+func AddMovieTags(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+
+		var req struct {
+			Tags []string `json:"tags" validate:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		tags, err := normalizeTags(req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "details": err.Error()})
+			return
+		}
+
+		updateMovieTags(c, client, movieId,
+			bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": tags}}, "$set": bson.M{"updated_at": time.Now()}},
+			bson.M{"added_tags": tags})
+	}
+}
+
+// RemoveMovieTag 移除电影标签的处理器函数（仅管理员）
+func RemoveMovieTag(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			return
+		}
+		tag, err := normalizeTag(c.Param("tag"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag", "details": err.Error()})
+			return
+		}
+
+		updateMovieTags(c, client, movieId,
+			bson.M{"$pull": bson.M{"tags": tag}, "$set": bson.M{"updated_at": time.Now()}},
+			bson.M{"removed_tag": tag})
+	}
+}
+
+// updateMovieTags 执行标签更新并返回电影更新后的标签列表
+func updateMovieTags(c *gin.Context, client *mongo.Client, movieId string, update bson.M, auditDetails bson.M) {
+	var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var movie models.Movie
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+	err := movieCollection.FindOneAndUpdate(ctx,
+		unlockedMovieFilter(movieId),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"tags": 1}),
+	).Decode(&movie)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		respondMovieNotUpdated(ctx, c, movieCollection, movieId)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie tags"})
+		return
+	}
+
+	invalidateRecommendations()
+	actorId, _ := utils.GetUserIdFromContext(c)
+	recordAudit(ctx, client, models.AuditActionUpdate, actorId, movieId, auditDetails)
+
+	if movie.Tags == nil {
+		movie.Tags = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"imdb_id": movieId, "tags": movie.Tags})
+}
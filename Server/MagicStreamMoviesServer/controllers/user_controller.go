@@ -2,18 +2,23 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,12 +35,22 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		var user models.User
 
 		if err := c.ShouldBindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			respondBindError(c, err)
 			return
 		}
-		validate := validator.New()
+		// 自助注册始终创建普通用户，忽略请求体中的角色；管理员角色只能由管理员通过 PUT /admin/users/:id/role 授予
+		user.Role = models.RoleUser
+		genres, err := normalizeGenres(user.FavouriteGenres)
+		if err != nil {
+			respondFieldError(c, "favourite_genres", err)
+			return
+		}
+		user.FavouriteGenres = genres
 		if err := validate.Struct(user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			respondValidationError(c, err)
+			return
+		}
+		if !checkFavouriteGenresLimit(c, user.FavouriteGenres) {
 			return
 		}
 
@@ -46,7 +61,16 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		}
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 		defer cancel()
+
+		// 注册时就校验喜爱的类型真实存在，否则推荐从第一次登录起就匹配不到任何电影
+		knownGenres, ok := resolveKnownGenres(ctx, c, client, user.FavouriteGenres)
+		if !ok {
+			return
+		}
+		user.FavouriteGenres = knownGenres
+
 		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		// 预检查只用于尽早返回，并发注册时以 email 唯一索引的冲突错误为准
 		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing user"})
@@ -62,6 +86,10 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		user.Password = hashedPassword
 
 		result, err := userCollection.InsertOne(ctx, user)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
@@ -70,11 +98,20 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 	}
 }
 
+// LoginUser 处理用户登录请求
+// 默认通过 HttpOnly Cookie 下发令牌；无法使用 Cookie 的客户端（移动端、CLI 等）可以传入 token_delivery=body，
+// 此时响应体中同时返回 token 和 refresh_token，之后通过 Authorization: Bearer 请求头认证，
+// 刷新时把 refresh_token 放在 X-Refresh-Token 请求头或 /refresh 的请求体中
 func LoginUser(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		tokensInBody, ok := parseTokenDelivery(c)
+		if !ok {
+			return
+		}
+
 		var userLogin models.UserLogin
 		if err := c.ShouldBindJSON(&userLogin); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			respondBindError(c, err)
 			return
 		}
 
@@ -82,7 +119,7 @@ func LoginUser(client *mongo.Client) gin.HandlerFunc {
 		defer cancel()
 		var foundUser models.User
 		var userCollection *mongo.Collection = database.OpenCollection("users", client)
-		err := userCollection.FindOne(ctx, bson.M{"email": userLogin.Email}).Decode(&foundUser)
+		err := database.FindOne(ctx, userCollection, bson.M{"email": userLogin.Email}, &foundUser)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
@@ -95,71 +132,164 @@ func LoginUser(client *mongo.Client) gin.HandlerFunc {
 		}
 
 		// 生成 JWT 访问令牌和刷新令牌
-		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID)
+		refreshTokenId := utils.NewTokenID()
+		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID, refreshTokenId, foundUser.TokenVersion)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 			return
 		}
+		if err := addRefreshSession(ctx, userCollection, foundUser.UserID, refreshTokenId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating session"})
+			return
+		}
 
 		// 注意：使用 HttpOnly Cookie 存储 token，不再将 token 保存到数据库
 		// 这样更安全，因为：
 		// 1. 减少数据库存储负担
 		// 2. token 只在 Cookie 中，后端无状态（stateless）
 		// 3. 过期后自动失效，无需手动清理数据库
+		setAuthCookies(c, token, refreshToken)
 
-		// 根据环境配置 Cookie 安全设置
-		// 开发环境(HTTP): Secure=false, SameSite=Lax
-		// 生产环境(HTTPS): Secure=true, SameSite=None (允许跨域)
-		isProduction := os.Getenv("ENV") == "production"
-		sameSiteMode := http.SameSiteLaxMode
-		secureFlag := false
-
-		if isProduction {
-			sameSiteMode = http.SameSiteNoneMode
-			secureFlag = true
+		response := models.UserResponse{
+			UserID:          foundUser.UserID,
+			FirstName:       foundUser.FirstName,
+			LastName:        foundUser.LastName,
+			Email:           foundUser.Email,
+			Role:            foundUser.Role,
+			FavouriteGenres: foundUser.FavouriteGenres,
+		}
+		// 浏览器默认只依赖 Cookie，令牌不出现在响应体中，避免被脚本读取
+		if tokensInBody {
+			response.Token = token
+			response.RefreshToken = refreshToken
 		}
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-		// 设置访问令牌 Cookie
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "access_token",
-			Value:    token,
-			HttpOnly: true,         // 防止 XSS 攻击，JavaScript 无法访问
-			Secure:   secureFlag,   // 开发环境: false, 生产环境: true
-			MaxAge:   86400,        // 24小时
-			SameSite: sameSiteMode, // 开发环境: Lax, 生产环境: None
-			Path:     "/",
-		})
+// maxRefreshSessions 每个用户同时保留的刷新令牌（登录会话）数量，超出时最早的会话失效
+const maxRefreshSessions = 10
 
-		// 设置刷新令牌 Cookie
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshToken,
-			HttpOnly: true,         // 防止 XSS 攻击
-			Secure:   secureFlag,   // 开发环境: false, 生产环境: true
-			MaxAge:   604800,       // 7天
-			SameSite: sameSiteMode, // 开发环境: Lax, 生产环境: None
-			Path:     "/",
-		})
-		c.JSON(http.StatusOK, models.UserResponse{
-			UserID:    foundUser.UserID,
-			FirstName: foundUser.FirstName,
-			LastName:  foundUser.LastName,
-			Email:     foundUser.Email,
-			Role:      foundUser.Role,
-			// Token:           token,
-			// RefreshToken:    refreshToken,
-			FavouriteGenres: foundUser.FavouriteGenres,
-		})
+// maxRotatedRefreshTokenIDs 每个用户保留的已轮换刷新令牌标识数量，用于识别重放
+const maxRotatedRefreshTokenIDs = 100
+
+// addRefreshSession 登录时记录新会话的刷新令牌标识
+func addRefreshSession(ctx context.Context, userCollection *mongo.Collection, userId, refreshTokenId string) error {
+	_, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$push": bson.M{"refresh_token_ids": bson.M{"$each": bson.A{refreshTokenId}, "$slice": -maxRefreshSessions}}},
+	)
+	return err
+}
+
+// setAuthCookies 通过 HttpOnly Cookie 下发访问令牌和刷新令牌
+// 根据环境配置 Cookie 安全设置
+// 开发环境(HTTP): Secure=false, SameSite=Lax
+// 生产环境(HTTPS): Secure=true, SameSite=None (允许跨域)
+func setAuthCookies(c *gin.Context, token, refreshToken string) {
+	isProduction := os.Getenv("ENV") == "production"
+	sameSiteMode := http.SameSiteLaxMode
+	secureFlag := false
+
+	if isProduction {
+		sameSiteMode = http.SameSiteNoneMode
+		secureFlag = true
+	}
+
+	// 设置访问令牌 Cookie
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "access_token",
+		Value:    token,
+		HttpOnly: true,         // 防止 XSS 攻击，JavaScript 无法访问
+		Secure:   secureFlag,   // 开发环境: false, 生产环境: true
+		MaxAge:   86400,        // 24小时
+		SameSite: sameSiteMode, // 开发环境: Lax, 生产环境: None
+		Path:     "/",
+	})
+
+	// 设置刷新令牌 Cookie
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		HttpOnly: true,         // 防止 XSS 攻击
+		Secure:   secureFlag,   // 开发环境: false, 生产环境: true
+		MaxAge:   604800,       // 7天
+		SameSite: sameSiteMode, // 开发环境: Lax, 生产环境: None
+		Path:     "/",
+	})
+}
+
+// parseTokenDelivery 解析 token_delivery 查询参数，返回是否需要在响应体中返回令牌
+// 支持 cookie（默认）和 body，其他取值写入 400 响应并返回 ok=false
+func parseTokenDelivery(c *gin.Context) (tokensInBody bool, ok bool) {
+	switch c.DefaultQuery("token_delivery", "cookie") {
+	case "cookie":
+		return false, true
+	case "body":
+		return true, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token_delivery must be cookie or body"})
+		return false, false
 	}
 }
 
+// TokenVersionLookup 返回从 users 集合读取用户令牌版本的函数，启动时传给 utils.SetTokenVersionLookup
+func TokenVersionLookup(client *mongo.Client) utils.TokenVersionLookup {
+	var userCollection *mongo.Collection = database.OpenCollection("users", client)
+	return func(ctx context.Context, userId string) (int, error) {
+		var user models.User
+		err := database.FindOne(ctx, userCollection, bson.M{"user_id": userId}, &user,
+			options.FindOne().SetProjection(bson.M{"token_version": 1}))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, utils.ErrTokenUserNotFound
+		}
+		return user.TokenVersion, err
+	}
+}
+
+// logoutUserId 从访问令牌或刷新令牌中识别要登出的用户，两者都无效时返回空字符串
+// 无法查询令牌版本时返回 utils.ErrTokenCheckUnavailable，此时不能确认会话已被撤销
+func logoutUserId(c *gin.Context) (string, error) {
+	var checkErr error
+	if token, err := utils.GetRequestAccessToken(c); err == nil && token != "" {
+		claims, err := utils.ValidateToken(token)
+		if err == nil {
+			return claims.UserID, nil
+		}
+		if errors.Is(err, utils.ErrTokenCheckUnavailable) {
+			checkErr = err
+		}
+	}
+	if token, err := c.Cookie("refresh_token"); err == nil && token != "" {
+		claims, err := utils.ValidateRefreshToken(token)
+		if err == nil {
+			return claims.UserID, nil
+		}
+		if errors.Is(err, utils.ErrTokenCheckUnavailable) {
+			checkErr = err
+		}
+	}
+	return "", checkErr
+}
+
 // LogoutHandler 处理用户登出请求
-// 通过删除 HttpOnly Cookie 来清除客户端的认证信息
+// 递增用户的令牌版本并清空有效的刷新令牌，使该用户此前签发的所有访问令牌和刷新令牌立即失效（所有设备都会登出），
+// 然后删除 HttpOnly Cookie；请求中没有有效令牌时只删除 Cookie
 func LogoutHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 注意：使用 Cookie 方案后，登出只需要删除客户端的 Cookie
-		// 不需要从数据库删除 token（因为我们已经不在数据库存储 token 了）
-		// Token 过期后会自动失效
+		userId, revokeErr := logoutUserId(c)
+		if userId != "" {
+			var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+			defer cancel()
+			var userCollection *mongo.Collection = database.OpenCollection("users", client)
+			_, revokeErr = userCollection.UpdateOne(ctx,
+				bson.M{"user_id": userId},
+				bson.M{
+					"$inc": bson.M{"token_version": 1},
+					"$set": bson.M{"refresh_token_ids": bson.A{}, "updated_at": time.Now()},
+				},
+			)
+		}
 
 		// 根据环境配置 Cookie 设置（与登录时保持一致）
 		isProduction := os.Getenv("ENV") == "production"
@@ -194,49 +324,505 @@ func LogoutHandler(client *mongo.Client) gin.HandlerFunc {
 			SameSite: sameSiteMode,
 		})
 
+		if errors.Is(revokeErr, utils.ErrTokenCheckUnavailable) {
+			logger.Error("error verifying token version on logout", "error", revokeErr)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token, please retry", "code": "TOKEN_CHECK_UNAVAILABLE"})
+			return
+		}
+		if revokeErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 	}
 }
+
+// refreshTokenRequest 不使用 Cookie 的客户端提交刷新令牌的请求体
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshTokenFromRequest 读取请求携带的刷新令牌
+// 优先读取 refresh_token Cookie，其次是 X-Refresh-Token 请求头或请求体 {"refresh_token": "..."}；
+// fromCookie 为 false 时令牌由客户端自行保存，新令牌需要在响应体中返回。读取失败时已写入响应，ok 为 false
+func refreshTokenFromRequest(c *gin.Context) (token string, fromCookie bool, ok bool) {
+	if token, err := c.Cookie("refresh_token"); err == nil && token != "" {
+		return token, true, true
+	}
+	if token := strings.TrimSpace(c.GetHeader("X-Refresh-Token")); token != "" {
+		return token, false, true
+	}
+
+	var req refreshTokenRequest
+	// 请求体可以为空，此时视为没有提供刷新令牌
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return "", false, false
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is required in the refresh_token cookie, X-Refresh-Token header or request body"})
+		return "", false, false
+	}
+	return req.RefreshToken, false, true
+}
+
+// RefreshTokenHandler 使用刷新令牌换取新的访问令牌和刷新令牌
+// 刷新令牌只能使用一次：出示的 jti 在用户文档中原子地替换为新令牌的 jti，旧的 jti 记入 rotated_refresh_token_ids。
+// 已经轮换过的刷新令牌再次出现说明令牌可能被盗用，此时撤销该用户的所有会话并返回 401；
+// 因会话数超过 maxRefreshSessions 被挤掉的会话只返回普通的 401，不影响其他设备
 func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
-		refreshToken, err := c.Cookie("refresh_token")
-
-		if err != nil {
-			fmt.Println("error", err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unable to retrieve refresh token from cookie"})
+		refreshToken, fromCookie, ok := refreshTokenFromRequest(c)
+		if !ok {
 			return
 		}
 
 		claim, err := utils.ValidateRefreshToken(refreshToken)
+		if errors.Is(err, utils.ErrTokenCheckUnavailable) {
+			logger.Error("error verifying refresh token version", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token, please retry", "code": "TOKEN_CHECK_UNAVAILABLE"})
+			return
+		}
 		if err != nil || claim == nil {
-			fmt.Println("error", err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			fmt.Println("error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token", "details": fmt.Sprint(err)})
+			return
+		}
+
+		// 轮换之前签发的刷新令牌没有 jti，无法判断是否已被使用，要求重新登录
+		if claim.ID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token is no longer valid, please log in again"})
 			return
 		}
 
 		var userCollection *mongo.Collection = database.OpenCollection("users", client)
 
+		// 只有出示的 jti 仍然有效时才替换为新的 jti，检查和替换在同一次原子操作中完成，同一个令牌不能被使用两次
+		newRefreshTokenId := utils.NewTokenID()
 		var user models.User
-		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: claim.UserID}}).Decode(&user)
-
+		err = userCollection.FindOneAndUpdate(ctx,
+			bson.M{"user_id": claim.UserID, "refresh_token_ids": claim.ID},
+			bson.M{
+				"$set":  bson.M{"refresh_token_ids.$": newRefreshTokenId},
+				"$push": bson.M{"rotated_refresh_token_ids": bson.M{"$each": bson.A{claim.ID}, "$slice": -maxRotatedRefreshTokenIDs}},
+			},
+			options.FindOneAndUpdate().SetProjection(bson.M{"password": 0}),
+		).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			rejectInactiveRefreshToken(ctx, c, userCollection, claim.UserID, claim.ID)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error refreshing session"})
 			return
 		}
 
-		newToken, newRefreshToken, _ := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID)
-		err = utils.UpdateAllTokens(user.UserID, newToken, newRefreshToken, client)
+		// 令牌只通过 Cookie 下发，不再写回数据库
+		newToken, newRefreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID, newRefreshTokenId, user.TokenVersion)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 			return
 		}
 
-		c.SetCookie("access_token", newToken, 86400, "/", "localhost", true, true)          // expires in 24 hours
-		c.SetCookie("refresh_token", newRefreshToken, 604800, "/", "localhost", true, true) //expires in 1 week
+		setAuthCookies(c, newToken, newRefreshToken)
 
+		// 浏览器只依赖 Cookie；通过请求头或请求体出示刷新令牌的客户端（token_delivery=body 登录）从响应体取得新令牌
+		if !fromCookie {
+			c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed", "token": newToken, "refresh_token": newRefreshToken})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed"})
 	}
 }
+
+// rejectInactiveRefreshToken 刷新令牌的 jti 不在有效列表中时调用
+// jti 在已轮换列表中说明令牌被重放（可能被盗用），撤销该用户的所有会话并递增令牌版本，已签发的访问令牌同时失效；
+// 否则是被挤掉的会话或已不存在的用户，只返回普通的 401
+func rejectInactiveRefreshToken(ctx context.Context, c *gin.Context, userCollection *mongo.Collection, userId, refreshTokenId string) {
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"user_id": userId, "rotated_refresh_token_ids": refreshTokenId},
+		bson.M{
+			"$set": bson.M{"refresh_token_ids": bson.A{}, "updated_at": time.Now()},
+			"$inc": bson.M{"token_version": 1},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking sessions"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Refresh session has expired, please log in again",
+			"code":  "REFRESH_SESSION_EXPIRED",
+		})
+		return
+	}
+
+	logger.Warn("refresh token reuse detected, all sessions revoked", "user_id", userId)
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Refresh token has already been used, all sessions have been revoked, please log in again",
+		"code":  "REFRESH_TOKEN_REUSED",
+	})
+}
+
+// ValidateTokenHandler 校验 JWT 访问令牌但不执行任何操作
+// 令牌优先从请求体 {"token": "..."} 中读取，否则读取 access_token Cookie 或 Authorization: Bearer 请求头
+// 供 API 网关做认证委托，或用于排查会话被拒绝的原因
+func ValidateTokenHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Token string `json:"token"`
+		}
+		// 请求体可以为空，此时回退到 Cookie
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondBindError(c, err)
+			return
+		}
+
+		token := req.Token
+		if token == "" {
+			requestToken, err := utils.GetRequestAccessToken(c)
+			if err != nil || requestToken == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required: no token found in body, cookie or authorization header"})
+				return
+			}
+			token = requestToken
+		}
+
+		claims, err := utils.ValidateToken(token)
+		if errors.Is(err, utils.ErrTokenCheckUnavailable) {
+			logger.Error("error verifying token version", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token, please retry", "code": "TOKEN_CHECK_UNAVAILABLE"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"valid": false, "error": err.Error()})
+			return
+		}
+
+		// 只返回非敏感的声明信息
+		resp := gin.H{
+			"valid":      true,
+			"user_id":    claims.UserID,
+			"email":      claims.Email,
+			"first_name": claims.FirstName,
+			"last_name":  claims.LastName,
+			"role":       claims.Role,
+			"expires_at": claims.ExpiresAt.Time,
+		}
+		if claims.IssuedAt != nil {
+			resp["issued_at"] = claims.IssuedAt.Time
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetCurrentUser 获取当前登录用户资料的处理器函数
+// 从上下文读取用户 ID 并返回 UserResponse（不含密码哈希和令牌），令牌签发后用户被删除时返回 404
+// 传入 ?include=taste 时额外返回 genre_breakdown：用户评论过的电影按类型统计的数量，默认不计算以保持请求轻量
+func GetCurrentUser(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		// 资料接口不需要密码哈希，查询时直接排除，避免其离开数据库
+		var user models.User
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		err = database.FindOne(ctx, userCollection, bson.M{"user_id": userId}, &user,
+			options.FindOne().SetProjection(bson.M{"password": 0}))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		resp := models.UserResponse{
+			UserID:          user.UserID,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			Email:           user.Email,
+			Role:            user.Role,
+			FavouriteGenres: user.FavouriteGenres,
+		}
+
+		if c.Query("include") == "taste" {
+			breakdown, err := getGenreBreakdown(ctx, client, userId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing genre breakdown"})
+				return
+			}
+			resp.GenreBreakdown = breakdown
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// getGenreBreakdown 统计用户评论过的电影在各类型中的数量
+// 通过聚合关联 reviews 与 movies，按电影类型分组计数
+func getGenreBreakdown(ctx context.Context, client *mongo.Client, userId string) (map[string]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "movies",
+			"localField":   "imdb_id",
+			"foreignField": "imdb_id",
+			"as":           "movie",
+		}}},
+		{{Key: "$unwind", Value: "$movie"}},
+		{{Key: "$unwind", Value: "$movie.genre"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$movie.genre.genre_name",
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	var results []struct {
+		GenreName string `bson:"_id"`
+		Count     int    `bson:"count"`
+	}
+	var reviewCollection *mongo.Collection = database.OpenCollection("reviews", client)
+	if err := database.AggregateAll(ctx, reviewCollection, pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	breakdown := make(map[string]int, len(results))
+	for _, result := range results {
+		breakdown[result.GenreName] = result.Count
+	}
+	return breakdown, nil
+}
+
+// validatePasswordStrength 检查新密码至少同时包含字母和数字，长度由 PasswordChange 的校验规则限制
+func validatePasswordStrength(password string) error {
+	hasLetter, hasDigit := false, false
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("password must contain at least one letter and one digit")
+	}
+	return nil
+}
+
+// ChangePassword 修改当前用户密码的处理器函数
+// 请求体为 {"old_password": "...", "new_password": "..."}，旧密码错误返回 401，新密码强度不足或与旧密码相同返回 400
+// 修改后撤销该用户此前签发的所有令牌，其他会话需要重新登录；
+// 当前会话通过 Cookie 获得新的令牌，不会被登出
+func ChangePassword(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var req models.PasswordChange
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		if err := validatePasswordStrength(req.NewPassword); err != nil {
+			respondFieldError(c, "new_password", err)
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		err = database.FindOne(ctx, userCollection, bson.M{"user_id": userId}, &user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+		if req.NewPassword == req.OldPassword {
+			respondFieldError(c, "new_password", errors.New("new password must differ from the old password"))
+			return
+		}
+
+		hashedPassword, err := HashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+			return
+		}
+
+		// 递增令牌版本使其他会话的访问令牌立即失效，有效的刷新令牌只保留当前会话新签发的一个
+		refreshTokenId := utils.NewTokenID()
+		now := time.Now()
+		err = userCollection.FindOneAndUpdate(ctx,
+			bson.M{"user_id": userId},
+			bson.M{
+				"$set": bson.M{
+					"password":            hashedPassword,
+					"password_changed_at": now,
+					"refresh_token_ids":   bson.A{refreshTokenId},
+					"updated_at":          now,
+				},
+				"$inc": bson.M{"token_version": 1},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"token_version": 1}),
+		).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating password"})
+			return
+		}
+
+		token, refreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID, refreshTokenId, user.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
+			return
+		}
+		setAuthCookies(c, token, refreshToken)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+	}
+}
+
+// UpdateFavouriteGenres 更新当前用户喜爱类型的处理器函数
+// 提交的类型按名称（忽略大小写）去重，数量上限由 MAX_FAVOURITE_GENRES 配置（默认 10），
+// 超出上限返回 400；响应中返回实际存储的类型列表，便于客户端与服务端保持一致
+func UpdateFavouriteGenres(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found in context"})
+			return
+		}
+
+		var req models.FavouriteGenresUpdate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		genres, err := normalizeGenres(req.FavouriteGenres)
+		if err != nil {
+			respondFieldError(c, "favourite_genres", err)
+			return
+		}
+		req.FavouriteGenres = genres
+
+		if err := validate.Struct(req); err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		if !checkFavouriteGenresLimit(c, genres) {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		genres, ok := resolveKnownGenres(ctx, c, client, genres)
+		if !ok {
+			return
+		}
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userId},
+			bson.M{"$set": bson.M{"favourite_genres": genres, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating favourite genres"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"favourite_genres": genres})
+	}
+}
+
+// maxFavouriteGenres 从环境变量 MAX_FAVOURITE_GENRES 获取每个用户喜爱类型的数量上限，默认为 10
+// 所有写入 favourite_genres 的入口都通过 checkFavouriteGenresLimit 使用这个上限，避免推荐查询的 $in 列表过大
+func maxFavouriteGenres() int {
+	if value, err := strconv.Atoi(os.Getenv("MAX_FAVOURITE_GENRES")); err == nil && value > 0 {
+		return value
+	}
+	return 10
+}
+
+// checkFavouriteGenresLimit 校验喜爱类型数量不超过上限，超出时写入 400 响应并返回 false
+// 应在 normalizeGenres 去重之后调用，重复项不计入数量
+func checkFavouriteGenresLimit(c *gin.Context, genres []models.Genre) bool {
+	maxGenres := maxFavouriteGenres()
+	if len(genres) > maxGenres {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      fmt.Sprintf("Too many favourite genres, at most %d allowed", maxGenres),
+			"max_genres": maxGenres,
+		})
+		return false
+	}
+	return true
+}
+
+// resolveKnownGenres 校验每个类型都存在于 genres 集合中（genre_id 相同且名称忽略大小写相同），
+// 并替换为集合中的规范写法；存在未知类型时写入 400 响应并返回 ok=false
+func resolveKnownGenres(ctx context.Context, c *gin.Context, client *mongo.Client, genres []models.Genre) ([]models.Genre, bool) {
+	var known []models.Genre
+	var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+	if err := database.FindAll(ctx, genreCollection, bson.M{}, &known); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching genres"})
+		return nil, false
+	}
+
+	byId := make(map[int]models.Genre, len(known))
+	for _, genre := range known {
+		byId[genre.GenreID] = genre
+	}
+
+	resolved := make([]models.Genre, 0, len(genres))
+	unknown := []models.Genre{}
+	for _, genre := range genres {
+		match, found := byId[genre.GenreID]
+		if !found || !strings.EqualFold(match.GenreName, genre.GenreName) {
+			unknown = append(unknown, genre)
+			continue
+		}
+		resolved = append(resolved, match)
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown genres", "unknown_genres": unknown})
+		return nil, false
+	}
+	return resolved, true
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// createTestUser 直接写入一个用户文档，返回其 user_id
+func createTestUser(t *testing.T, client *mongo.Client, email, password string, genres ...models.Genre) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hashed, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if genres == nil {
+		genres = []models.Genre{}
+	}
+	user := models.User{
+		UserID:          bson.NewObjectID().Hex(),
+		FirstName:       "Test",
+		LastName:        "User",
+		Email:           email,
+		Password:        hashed,
+		Role:            models.RoleUser,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		FavouriteGenres: genres,
+	}
+	if _, err := database.OpenCollection("users", client).InsertOne(ctx, user); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return user.UserID
+}
+
+// useTokenChecks 使用测试签名密钥，并按 users 集合中的令牌版本校验令牌
+func useTokenChecks(t *testing.T, client *mongo.Client) {
+	t.Helper()
+	originalKey, originalRefreshKey := utils.SECRET_KEY, utils.SECRET_REFRESH_KEY
+	utils.SECRET_KEY, utils.SECRET_REFRESH_KEY = "test-access-secret", "test-refresh-secret"
+	utils.SetTokenVersionLookup(TokenVersionLookup(client))
+	t.Cleanup(func() {
+		utils.SECRET_KEY, utils.SECRET_REFRESH_KEY = originalKey, originalRefreshKey
+		utils.SetTokenVersionLookup(nil)
+	})
+}
+
+// responseCookie 返回响应中指定名称的 Cookie
+func responseCookie(t *testing.T, w *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name && cookie.Value != "" {
+			return cookie
+		}
+	}
+	t.Fatalf("response has no %s cookie", name)
+	return nil
+}
+
+// login 调用登录接口并返回访问令牌和刷新令牌 Cookie
+func login(t *testing.T, router http.Handler, email, password string) (*http.Cookie, *http.Cookie) {
+	t.Helper()
+	w := performJSON(router, http.MethodPost, "/login", gin.H{"email": email, "password": password})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d; body %s", w.Code, w.Body.String())
+	}
+	return responseCookie(t, w, "access_token"), responseCookie(t, w, "refresh_token")
+}
+
+// newAuthRouter 注册登录、登出、刷新和需要认证的 /me 路由
+func newAuthRouter(client *mongo.Client) *gin.Engine {
+	router := gin.New()
+	router.POST("/login", LoginUser(client))
+	router.POST("/logout", LogoutHandler(client))
+	router.POST("/refresh", RefreshTokenHandler(client))
+	router.GET("/me", middleware.AuthMiddleware(), GetCurrentUser(client))
+	return router
+}
+
+func TestLogoutRevokesIssuedAccessToken(t *testing.T) {
+	client := testClient(t)
+	useTokenChecks(t, client)
+	createTestUser(t, client, "alice@example.com", "password123")
+	router := newAuthRouter(client)
+
+	accessToken, refreshToken := login(t, router, "alice@example.com", "password123")
+	if w := performJSON(router, http.MethodGet, "/me", nil, accessToken); w.Code != http.StatusOK {
+		t.Fatalf("GET /me before logout status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	if w := performJSON(router, http.MethodPost, "/logout", nil, accessToken, refreshToken); w.Code != http.StatusOK {
+		t.Fatalf("logout status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	if w := performJSON(router, http.MethodGet, "/me", nil, accessToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me with old access token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := performJSON(router, http.MethodPost, "/refresh", nil, refreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with old refresh token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// performWithHeaders 发送不带 Cookie 的 JSON 请求，附加指定的请求头
+func performWithHeaders(router http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// bodyTokens 读取响应体中的访问令牌和刷新令牌
+func bodyTokens(t *testing.T, w *httptest.ResponseRecorder) (string, string) {
+	t.Helper()
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode tokens: %v; body %s", err, w.Body.String())
+	}
+	if body.Token == "" || body.RefreshToken == "" {
+		t.Fatalf("response has no token or refresh_token; body %s", w.Body.String())
+	}
+	return body.Token, body.RefreshToken
+}
+
+func TestBodyTokenDeliveryWorksWithoutCookies(t *testing.T) {
+	client := testClient(t)
+	useTokenChecks(t, client)
+	createTestUser(t, client, "bob@example.com", "password123")
+	router := newAuthRouter(client)
+
+	w := performJSON(router, http.MethodPost, "/login?token_delivery=body", gin.H{"email": "bob@example.com", "password": "password123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d; body %s", w.Code, w.Body.String())
+	}
+	accessToken, refreshToken := bodyTokens(t, w)
+
+	if w := performWithHeaders(router, http.MethodGet, "/me", nil, map[string]string{"Authorization": "Bearer " + accessToken}); w.Code != http.StatusOK {
+		t.Fatalf("GET /me with bearer token status = %d; body %s", w.Code, w.Body.String())
+	}
+	if w := performWithHeaders(router, http.MethodGet, "/me", nil, map[string]string{"Authorization": "Basic " + accessToken}); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me with non-bearer scheme status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// 刷新令牌放在请求体中
+	w = performWithHeaders(router, http.MethodPost, "/refresh", gin.H{"refresh_token": refreshToken}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh with body token status = %d; body %s", w.Code, w.Body.String())
+	}
+	accessToken, refreshToken = bodyTokens(t, w)
+	if w := performWithHeaders(router, http.MethodGet, "/me", nil, map[string]string{"Authorization": "Bearer " + accessToken}); w.Code != http.StatusOK {
+		t.Fatalf("GET /me with refreshed token status = %d; body %s", w.Code, w.Body.String())
+	}
+
+	// 刷新令牌放在请求头中
+	w = performWithHeaders(router, http.MethodPost, "/refresh", nil, map[string]string{"X-Refresh-Token": refreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh with header token status = %d; body %s", w.Code, w.Body.String())
+	}
+	bodyTokens(t, w)
+
+	if w := performWithHeaders(router, http.MethodPost, "/refresh", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh without any token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRefreshTokenFromRequestSources(t *testing.T) {
+	newContext := func(body string) (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		return c, w
+	}
+
+	c, _ := newContext("")
+	c.Request.AddCookie(&http.Cookie{Name: "refresh_token", Value: "cookie-token"})
+	if token, fromCookie, ok := refreshTokenFromRequest(c); !ok || !fromCookie || token != "cookie-token" {
+		t.Errorf("cookie: got %q, fromCookie=%v, ok=%v", token, fromCookie, ok)
+	}
+
+	c, _ = newContext("")
+	c.Request.Header.Set("X-Refresh-Token", "header-token")
+	if token, fromCookie, ok := refreshTokenFromRequest(c); !ok || fromCookie || token != "header-token" {
+		t.Errorf("header: got %q, fromCookie=%v, ok=%v", token, fromCookie, ok)
+	}
+
+	c, _ = newContext(`{"refresh_token": "body-token"}`)
+	if token, fromCookie, ok := refreshTokenFromRequest(c); !ok || fromCookie || token != "body-token" {
+		t.Errorf("body: got %q, fromCookie=%v, ok=%v", token, fromCookie, ok)
+	}
+
+	c, w := newContext("")
+	if _, _, ok := refreshTokenFromRequest(c); ok || w.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: ok=%v, status %d, want 401", ok, w.Code)
+	}
+}
+
+// errorCode 读取错误响应中的 code 字段
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Code
+}
+
+func TestRefreshRotatesRefreshToken(t *testing.T) {
+	client := testClient(t)
+	useTokenChecks(t, client)
+	createTestUser(t, client, "bob@example.com", "password123")
+	router := newAuthRouter(client)
+
+	_, refreshToken := login(t, router, "bob@example.com", "password123")
+
+	for i := 0; i < 3; i++ {
+		w := performJSON(router, http.MethodPost, "/refresh", nil, refreshToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("refresh %d status = %d; body %s", i+1, w.Code, w.Body.String())
+		}
+		rotated := responseCookie(t, w, "refresh_token")
+		if rotated.Value == refreshToken.Value {
+			t.Fatalf("refresh %d returned the same refresh token", i+1)
+		}
+		accessToken := responseCookie(t, w, "access_token")
+		if w := performJSON(router, http.MethodGet, "/me", nil, accessToken); w.Code != http.StatusOK {
+			t.Fatalf("GET /me with refreshed access token status = %d; body %s", w.Code, w.Body.String())
+		}
+		refreshToken = rotated
+	}
+}
+
+func TestRefreshTokenReplayRevokesAllSessions(t *testing.T) {
+	client := testClient(t)
+	useTokenChecks(t, client)
+	createTestUser(t, client, "carol@example.com", "password123")
+	router := newAuthRouter(client)
+
+	_, stolen := login(t, router, "carol@example.com", "password123")
+	otherAccess, otherRefresh := login(t, router, "carol@example.com", "password123")
+
+	w := performJSON(router, http.MethodPost, "/refresh", nil, stolen)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first refresh status = %d; body %s", w.Code, w.Body.String())
+	}
+	current := responseCookie(t, w, "refresh_token")
+
+	// 已经轮换过的令牌再次出示
+	w = performJSON(router, http.MethodPost, "/refresh", nil, stolen)
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "REFRESH_TOKEN_REUSED" {
+		t.Fatalf("replayed refresh status = %d, code = %q; want 401 REFRESH_TOKEN_REUSED", w.Code, errorCode(t, w))
+	}
+
+	// 所有会话都被撤销，包括轮换后的令牌和其他设备
+	if w := performJSON(router, http.MethodPost, "/refresh", nil, current); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with rotated token after replay status = %d, want 401", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, "/refresh", nil, otherRefresh); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh from another session after replay status = %d, want 401", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/me", nil, otherAccess); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me from another session after replay status = %d, want 401", w.Code)
+	}
+}
+
+func TestEvictedRefreshSessionIsNotTreatedAsReuse(t *testing.T) {
+	client := testClient(t)
+	useTokenChecks(t, client)
+	createTestUser(t, client, "dave@example.com", "password123")
+	router := newAuthRouter(client)
+
+	_, evicted := login(t, router, "dave@example.com", "password123")
+	var latest *http.Cookie
+	for i := 0; i < maxRefreshSessions; i++ {
+		_, latest = login(t, router, "dave@example.com", "password123")
+	}
+
+	w := performJSON(router, http.MethodPost, "/refresh", nil, evicted)
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != "REFRESH_SESSION_EXPIRED" {
+		t.Fatalf("evicted refresh status = %d, code = %q; want 401 REFRESH_SESSION_EXPIRED", w.Code, errorCode(t, w))
+	}
+
+	// 其他会话不受影响
+	if w := performJSON(router, http.MethodPost, "/refresh", nil, latest); w.Code != http.StatusOK {
+		t.Errorf("refresh from an active session status = %d, want 200; body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterUserRejectsEmptyGenreNameAsFieldError(t *testing.T) {
+	router := gin.New()
+	router.POST("/register", RegisterUser(offlineClient(t)))
+
+	w := performJSON(router, http.MethodPost, "/register", gin.H{
+		"first_name":       "Alice",
+		"last_name":        "Example",
+		"email":            "alice@example.com",
+		"password":         "password123",
+		"favourite_genres": []gin.H{{"genre_id": 1, "genre_name": "  "}},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+
+	var body struct {
+		Code   string            `json:"code"`
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Code != "VALIDATION_FAILED" || body.Fields["favourite_genres"] == "" {
+		t.Errorf("body = %s, want VALIDATION_FAILED with a favourite_genres field error", w.Body.String())
+	}
+}
+
+func TestRegisterUserIgnoresRequestedRole(t *testing.T) {
+	client := testClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	genres := database.OpenCollection("genres", client)
+	if _, err := genres.InsertOne(ctx, models.Genre{GenreID: 1, GenreName: "Drama"}); err != nil {
+		t.Fatalf("insert genre: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/register", RegisterUser(client))
+
+	w := performJSON(router, http.MethodPost, "/register", gin.H{
+		"first_name":       "Mallory",
+		"last_name":        "Example",
+		"email":            "mallory@example.com",
+		"password":         "password123",
+		"role":             "admin",
+		"favourite_genres": []models.Genre{{GenreID: 1, GenreName: "Drama"}},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var user models.User
+	if err := database.OpenCollection("users", client).FindOne(ctx, bson.M{"email": "mallory@example.com"}).Decode(&user); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	if user.Role != models.RoleUser {
+		t.Errorf("role = %q, want %q", user.Role, models.RoleUser)
+	}
+}
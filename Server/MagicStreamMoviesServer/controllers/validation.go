@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator 创建数据验证器，校验错误中的字段名使用 json 标签名而不是 Go 字段名，
+// 这样返回给客户端的字段路径（如 genre[0].genre_name）与请求体中的键一致
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// fieldError 校验器之外的自定义检查（如类型规范化、描述长度）产生的单字段错误
+type fieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *fieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.Err
+}
+
+// validationFieldErrors 将校验错误转换为 字段路径 -> 失败规则 的映射
+// 字段路径去掉了顶层结构体名，例如 "genre[0].genre_name": "required"
+func validationFieldErrors(err error) map[string]string {
+	var fieldErr *fieldError
+	if errors.As(err, &fieldErr) {
+		return map[string]string{fieldErr.Field: fieldErr.Err.Error()}
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		path := fieldErr.Namespace()
+		if i := strings.Index(path, "."); i >= 0 {
+			path = path[i+1:]
+		}
+		rule := fieldErr.Tag()
+		if fieldErr.Param() != "" {
+			rule += "=" + fieldErr.Param()
+		}
+		fields[path] = rule
+	}
+	return fields
+}
+
+// respondValidationError 返回统一格式的校验失败响应，fields 中列出每个失败字段的路径和规则
+func respondValidationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Validation failed",
+		"code":    "VALIDATION_FAILED",
+		"details": err.Error(),
+		"fields":  validationFieldErrors(err),
+	})
+}
+
+// respondFieldError 返回单个字段的校验失败响应，格式与 respondValidationError 一致
+func respondFieldError(c *gin.Context, field string, err error) {
+	respondValidationError(c, &fieldError{Field: field, Err: err})
+}
+
+// validationMessage 将校验错误格式化为一行可读的说明，如 "genre[0].genre_name: required; title: min=2"
+func validationMessage(err error) string {
+	fields := validationFieldErrors(err)
+	if len(fields) == 0 {
+		return err.Error()
+	}
+
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	parts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		parts = append(parts, path+": "+fields[path])
+	}
+	return strings.Join(parts, "; ")
+}
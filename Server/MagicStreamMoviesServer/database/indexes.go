@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexSpec 期望存在的一个索引
+type indexSpec struct {
+	Collection string
+	Model      mongo.IndexModel
+}
+
+// expectedIndexes 服务依赖的索引，索引名固定，便于核对和排查
+var expectedIndexes = []indexSpec{
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "imdb_id", Value: 1}},
+		Options: options.Index().SetName("imdb_id_1"),
+	}},
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("created_at_-1__id_-1"),
+	}},
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: -1}},
+		Options: options.Index().SetName("updated_at_-1"),
+	}},
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "release_date", Value: 1}},
+		Options: options.Index().SetName("release_date_1"),
+	}},
+	// 全文搜索使用的文本索引，标题权重高于描述
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("movie_text").SetWeights(bson.M{"title": 10, "description": 1}),
+	}},
+	{"users", mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_id_1"),
+	}},
+	// 按演员、导演过滤以及人物作品列表，均为多键索引
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "cast_keys", Value: 1}},
+		Options: options.Index().SetName("cast_keys_1"),
+	}},
+	{"movies", mongo.IndexModel{
+		Keys:    bson.D{{Key: "director_keys", Value: 1}},
+		Options: options.Index().SetName("director_keys_1"),
+	}},
+	// 注册时以唯一索引作为邮箱冲突的最终判断，避免并发注册产生重复账号
+	{"users", mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_1").SetUnique(true),
+	}},
+	// 每个用户对每部电影只有一条评论
+	{"reviews", mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+		Options: options.Index().SetName("user_id_1_imdb_id_1").SetUnique(true),
+	}},
+	{"reviews", mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: -1}},
+		Options: options.Index().SetName("updated_at_-1"),
+	}},
+	// 电影评论列表的 recent 和 helpful 两种排序
+	{"reviews", mongo.IndexModel{
+		Keys:    bson.D{{Key: "imdb_id", Value: 1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("imdb_id_1_updated_at_-1__id_-1"),
+	}},
+	{"reviews", mongo.IndexModel{
+		Keys:    bson.D{{Key: "imdb_id", Value: 1}, {Key: "helpful_count", Value: -1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("imdb_id_1_helpful_count_-1_updated_at_-1__id_-1"),
+	}},
+	// 评分分布按 imdb_id 过滤后按 rating 分组，可以只扫描索引
+	{"reviews", mongo.IndexModel{
+		Keys:    bson.D{{Key: "imdb_id", Value: 1}, {Key: "rating", Value: 1}},
+		Options: options.Index().SetName("imdb_id_1_rating_1"),
+	}},
+	// 失败的 AI 排名每部电影只保留一条
+	{"failed_rankings", mongo.IndexModel{
+		Keys:    bson.D{{Key: "imdb_id", Value: 1}},
+		Options: options.Index().SetName("imdb_id_1").SetUnique(true),
+	}},
+	{"progress", mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+		Options: options.Index().SetName("user_id_1_imdb_id_1").SetUnique(true),
+	}},
+	{"audit_log", mongo.IndexModel{
+		Keys:    bson.D{{Key: "target_imdb_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("target_imdb_id_1_created_at_-1"),
+	}},
+	// GET /audit 按操作类型、操作者过滤或不带过滤条件翻页，均按时间倒序
+	{"audit_log", mongo.IndexModel{
+		Keys:    bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("action_1_created_at_-1"),
+	}},
+	{"audit_log", mongo.IndexModel{
+		Keys:    bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("actor_id_1_created_at_-1"),
+	}},
+	{"audit_log", mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("created_at_-1__id_-1"),
+	}},
+}
+
+// IndexResult 单个索引的创建结果，Error 为空表示索引已存在或创建成功
+type IndexResult struct {
+	Collection string `json:"collection"`
+	Name       string `json:"name"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EnsureIndexes 创建所有期望的索引，已存在的相同索引不会重复创建
+// 单个索引失败（例如已有重复数据导致唯一索引无法创建）不影响其它索引，所有失败合并到返回的 error 中
+func EnsureIndexes(ctx context.Context, client *mongo.Client) ([]IndexResult, error) {
+	results := make([]IndexResult, 0, len(expectedIndexes))
+	var errs []error
+	for _, spec := range expectedIndexes {
+		result := IndexResult{Collection: spec.Collection}
+		name, err := OpenCollection(spec.Collection, client).Indexes().CreateOne(ctx, spec.Model)
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", spec.Collection, err))
+		}
+		result.Name = name
+		if result.Name == "" {
+			result.Name = indexName(spec.Model)
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// ListIndexes 列出服务使用的各集合上当前存在的索引
+func ListIndexes(ctx context.Context, client *mongo.Client) (map[string][]bson.M, error) {
+	indexes := make(map[string][]bson.M)
+	for _, spec := range expectedIndexes {
+		if _, ok := indexes[spec.Collection]; ok {
+			continue
+		}
+
+		cursor, err := OpenCollection(spec.Collection, client).Indexes().List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.Collection, err)
+		}
+		list := []bson.M{}
+		if err := cursor.All(ctx, &list); err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.Collection, err)
+		}
+		indexes[spec.Collection] = list
+	}
+	return indexes, nil
+}
+
+// indexNotFoundCode MongoDB 缺少查询所需索引时的错误码（IndexNotFound），
+// 例如在没有文本索引的集合上执行 $text 查询
+const indexNotFoundCode = 27
+
+// IsIndexNotFound 判断错误是否由缺少所需索引引起
+func IsIndexNotFound(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFoundCode)
+}
+
+// indexName 返回索引模型中指定的名称
+func indexName(model mongo.IndexModel) string {
+	if model.Options == nil {
+		return ""
+	}
+	var opts options.IndexOptions
+	for _, setter := range model.Options.List() {
+		_ = setter(&opts)
+	}
+	if opts.Name == nil {
+		return ""
+	}
+	return *opts.Name
+}
@@ -0,0 +1,15 @@
+package database
+
+import (
+	"log/slog"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/logging"
+)
+
+// logger 数据库包使用的日志记录器，级别由 LOG_LEVEL_DATABASE 或 LOG_LEVEL 配置
+var logger = logging.New("database")
+
+// SetLogger 替换数据库包使用的日志记录器，供测试断言日志输出或调整输出方式
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
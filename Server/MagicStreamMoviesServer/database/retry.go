@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// 读操作重试参数
+const (
+	maxReadAttempts    = 3
+	initialReadBackoff = 100 * time.Millisecond
+)
+
+// retryableErrorCodes 副本集切换、节点关闭等场景下的瞬时错误码
+var retryableErrorCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// WithReadRetry 对幂等的读操作进行重试
+// 仅在网络错误或可重试的服务器错误（如主节点切换）时重试，采用指数退避，且不会超出请求上下文的截止时间
+// 写操作依赖驱动自带的 retryable writes（在 Connect 中开启），不要用此函数包装非幂等的写操作
+func WithReadRetry(ctx context.Context, operation func(ctx context.Context) error) error {
+	backoff := initialReadBackoff
+	for attempt := 1; ; attempt++ {
+		err := operation(ctx)
+		if err == nil || attempt >= maxReadAttempts || !isRetryableError(err) {
+			return err
+		}
+
+		// 剩余时间不足以等待下一次重试时直接返回
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableError 判断错误是否为可重试的瞬时错误
+func isRetryableError(err error) bool {
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	if serverErr.HasErrorLabel("RetryableReadError") {
+		return true
+	}
+	for _, code := range retryableErrorCodes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAll 带重试地执行 Find 并将所有结果解码到 results 中
+func FindAll(ctx context.Context, collection *mongo.Collection, filter any, results any, opts ...options.Lister[options.FindOptions]) error {
+	return WithReadRetry(ctx, func(ctx context.Context) error {
+		cursor, err := collection.Find(ctx, filter, opts...)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, results)
+	})
+}
+
+// AggregateAll 带重试地执行聚合管道并将所有结果解码到 results 中
+func AggregateAll(ctx context.Context, collection *mongo.Collection, pipeline any, results any) error {
+	return WithReadRetry(ctx, func(ctx context.Context) error {
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, results)
+	})
+}
+
+// 计数方式，返回给客户端以说明总数是否精确
+// 过滤条件总是非空的接口计数方式必然是 exact，不返回 count_type；只有可能不带过滤条件的总数（审计日志列表、统计报表）才返回
+const (
+	CountEstimated = "estimated" // 来自集合元数据的估算值，无过滤时使用
+	CountExact     = "exact"     // CountDocuments 的精确值，有过滤时使用
+)
+
+// Count 带重试地统计文档数量，返回数量和计数方式
+// 没有过滤条件时使用很快的 EstimatedDocumentCount，有过滤条件时使用精确的 CountDocuments
+func Count(ctx context.Context, collection *mongo.Collection, filter bson.M) (count int64, countType string, err error) {
+	err = WithReadRetry(ctx, func(ctx context.Context) error {
+		var err error
+		if len(filter) == 0 {
+			count, err = collection.EstimatedDocumentCount(ctx)
+			countType = CountEstimated
+		} else {
+			count, err = collection.CountDocuments(ctx, filter)
+			countType = CountExact
+		}
+		return err
+	})
+	return count, countType, err
+}
+
+// FindOne 带重试地执行 FindOne 并将结果解码到 result 中，未找到时返回 mongo.ErrNoDocuments
+func FindOne(ctx context.Context, collection *mongo.Collection, filter any, result any, opts ...options.Lister[options.FindOneOptions]) error {
+	return WithReadRetry(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, filter, opts...).Decode(result)
+	})
+}
@@ -0,0 +1,129 @@
+// Package features 提供按部署配置的功能开关
+// 开关在启动时从环境变量和 feature_flags 集合加载，处理器和路由通过 Enabled 判断功能是否开启
+package features
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/logging"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// 功能开关名称
+const (
+	// LLM 使用 AI 为管理员评论分配排名，关闭时与 LLM_DISABLED=true 相同，使用默认排名
+	LLM = "llm"
+	// Recommendations 推荐电影相关接口
+	Recommendations = "recommendations"
+	// UserReviews 用户评论和评分分布接口
+	UserReviews = "user_reviews"
+	// WatchProgress 播放进度和继续观看接口
+	WatchProgress = "watch_progress"
+)
+
+// 开关值的来源
+const (
+	SourceDefault    = "default"
+	SourceEnv        = "env"
+	SourceCollection = "collection"
+)
+
+// defaults 所有已知开关及其默认值，未在此列出的名称视为关闭
+var defaults = map[string]bool{
+	LLM:             true,
+	Recommendations: true,
+	UserReviews:     true,
+	WatchProgress:   true,
+}
+
+// Flag 一个开关的当前状态
+type Flag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+// logger 功能开关包使用的日志记录器，级别由 LOG_LEVEL_FEATURES 或 LOG_LEVEL 配置
+var logger = logging.New("features")
+
+// SetLogger 替换功能开关包使用的日志记录器
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+var (
+	mu    sync.RWMutex
+	flags = fromEnv()
+)
+
+// fromEnv 从默认值和环境变量 FEATURE_<NAME>（如 FEATURE_USER_REVIEWS=false）构建开关
+func fromEnv() map[string]Flag {
+	result := make(map[string]Flag, len(defaults))
+	for name, enabled := range defaults {
+		flag := Flag{Name: name, Enabled: enabled, Source: SourceDefault}
+		if value := os.Getenv("FEATURE_" + strings.ToUpper(name)); value != "" {
+			if parsed, err := strconv.ParseBool(value); err == nil {
+				flag.Enabled, flag.Source = parsed, SourceEnv
+			} else {
+				logger.Warn("ignoring invalid feature flag value", "name", name, "value", value)
+			}
+		}
+		result[name] = flag
+	}
+	return result
+}
+
+// Load 重新加载开关：先应用默认值和环境变量，再由 feature_flags 集合中的文档（{_id: 名称, enabled: bool}）覆盖
+// 读取集合失败时保留环境变量的结果并返回错误，调用方只需记录日志，不必阻止启动
+func Load(ctx context.Context, client *mongo.Client) error {
+	loaded := fromEnv()
+
+	var docs []struct {
+		Name    string `bson:"_id"`
+		Enabled bool   `bson:"enabled"`
+	}
+	var flagCollection *mongo.Collection = database.OpenCollection("feature_flags", client)
+	err := database.FindAll(ctx, flagCollection, bson.M{}, &docs)
+	if err == nil {
+		for _, doc := range docs {
+			if _, known := defaults[doc.Name]; !known {
+				logger.Warn("ignoring unknown feature flag", "name", doc.Name)
+				continue
+			}
+			loaded[doc.Name] = Flag{Name: doc.Name, Enabled: doc.Enabled, Source: SourceCollection}
+		}
+	}
+
+	mu.Lock()
+	flags = loaded
+	mu.Unlock()
+	return err
+}
+
+// Enabled 判断功能是否开启，未知的名称返回 false
+func Enabled(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return flags[name].Enabled
+}
+
+// Snapshot 返回所有开关的当前状态，按名称排序
+func Snapshot() []Flag {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]Flag, 0, len(flags))
+	for _, flag := range flags {
+		result = append(result, flag)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,28 @@
+// Package logging 提供基于 log/slog 的结构化日志，日志级别可以按组件通过环境变量配置
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// New 创建带 component 属性的日志记录器
+// 级别优先读取 LOG_LEVEL_<COMPONENT>（如 LOG_LEVEL_DATABASE），其次读取 LOG_LEVEL，默认 info；
+// 可选值为 debug、info、warn、error
+func New(component string) *slog.Logger {
+	level := ParseLevel(os.Getenv("LOG_LEVEL"), slog.LevelInfo)
+	level = ParseLevel(os.Getenv("LOG_LEVEL_"+strings.ToUpper(component)), level)
+
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	return slog.New(handler).With("component", component)
+}
+
+// ParseLevel 解析日志级别字符串，为空或无法识别时返回 defaultLevel
+func ParseLevel(value string, defaultLevel slog.Level) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return defaultLevel
+	}
+	return level
+}
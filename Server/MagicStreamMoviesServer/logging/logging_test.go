@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestNewReadsLevelAtCallTime(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_LEVEL_EXAMPLE", "")
+	if New("example").Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug enabled without LOG_LEVEL, want info default")
+	}
+
+	// 模拟 main 在加载 .env 之后重新创建日志记录器
+	t.Setenv("LOG_LEVEL", "debug")
+	if !New("example").Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug disabled after LOG_LEVEL=debug")
+	}
+
+	t.Setenv("LOG_LEVEL_EXAMPLE", "error")
+	if New("example").Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("warn enabled with LOG_LEVEL_EXAMPLE=error, want component level to win")
+	}
+}
@@ -2,21 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
+	controller "github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/controllers"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/logging"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/routes"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+// 构建信息，编译时通过 -ldflags 注入，例如：
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
 func main() {
 	// 创建一个默认的 Gin 路由器
 	router := gin.Default()
@@ -26,12 +43,27 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"message": "Server is running"})
 	})
 
+	// 版本信息端点，用于排查问题时确认当前运行的是哪个构建
+	router.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"version":    version,
+			"commit":     commit,
+			"build_time": buildTime,
+			"go_version": runtime.Version(),
+		})
+	})
+
 	// 加载 .env 环境变量文件
 	err := godotenv.Load(".env")
 	if err != nil {
 		log.Println("Warning: unable to find .env")
 	}
 
+	// 包级日志记录器在 .env 加载之前就已创建，这里按加载后的 LOG_LEVEL / LOG_LEVEL_<COMPONENT> 重新创建
+	controller.SetLogger(logging.New("controllers"))
+	database.SetLogger(logging.New("database"))
+	features.SetLogger(logging.New("features"))
+
 	// ==================== CORS 配置开始 ====================
 	// CORS (Cross-Origin Resource Sharing) 跨域资源共享
 	// 当前端（比如运行在 localhost:5173 的 React 应用）想要访问后端 API（运行在 localhost:8080）时，
@@ -65,11 +97,13 @@ func main() {
 	config.AllowMethods = []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"}
 
 	// AllowHeaders: 允许前端发送的请求头
-	// Origin: 请求来源, Content-Type: 内容类型（如 application/json）, Authorization: 认证令牌
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	// Origin: 请求来源, Content-Type: 内容类型（如 application/json）, Authorization: 认证令牌,
+	// X-Refresh-Token: 不使用 Cookie 的客户端刷新令牌时提交的刷新令牌
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-Refresh-Token"}
 
 	// ExposeHeaders: 允许前端 JavaScript 读取的响应头
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.ExposeHeaders = []string{"Content-Length", "X-Cache", "X-Computed-At", "X-Generated-At",
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"}
 
 	// AllowCredentials: 是否允许发送 Cookie 和认证信息
 	// 设为 true 时，前端可以在请求中携带 cookies、HTTP 认证及客户端 SSL 证书
@@ -86,8 +120,16 @@ func main() {
 	// 使用日志中间件，记录所有请求
 	router.Use(gin.Logger())
 
+	// 维护模式中间件：迁移期间可切换为只读或完全停机，/health 注册在此之前不受影响
+	router.Use(middleware.MaintenanceMiddleware())
+
+	// 带请求体的写请求必须使用 JSON，排名导入额外接受 CSV
+	router.Use(middleware.ContentTypeMiddleware(map[string][]string{
+		"/admin/rankings/import": {"text/csv"},
+	}))
+
 	// 连接到 MongoDB 数据库
-	var client *mongo.Client = database.Connect()
+	var client *mongo.Client = database.Connect(database.LoadConfig())
 
 	// 测试数据库连接是否成功
 	if err := client.Ping(context.Background(), nil); err != nil {
@@ -102,14 +144,77 @@ func main() {
 		}
 	}()
 
+	// 创建服务依赖的索引，失败时只记录日志，不阻止启动（可通过 POST /admin/indexes/ensure 重试）
+	indexCtx, indexCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if _, err := database.EnsureIndexes(indexCtx, client); err != nil {
+		log.Printf("Warning: failed to ensure indexes: %v", err)
+	}
+	indexCancel()
+
+	// 加载功能开关（环境变量 FEATURE_<NAME>，feature_flags 集合中的配置优先），读取集合失败时只使用环境变量
+	featureCtx, featureCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := features.Load(featureCtx, client); err != nil {
+		log.Printf("Warning: failed to load feature flags from database: %v", err)
+	}
+	featureCancel()
+
+	// 校验令牌时检查令牌版本，登出后此前签发的令牌立即失效
+	utils.SetTokenVersionLookup(controller.TokenVersionLookup(client))
+
+	// AI 排名使用的接口由 LLM_BASE_URL、LLM_MODEL、LLM_API_KEY 等环境变量配置，默认为 DeepSeek
+	controller.SetReviewRanker(controller.NewReviewRanker())
+
 	// 设置不需要认证的路由（如：登录、注册）
 	routes.SetupUnprotectedRoutes(router, client)
 
 	// 设置需要认证的路由（如：获取用户信息、修改数据）
 	routes.SetupProtectedRoutes(router, client)
 
+	// 未匹配的路由也返回 JSON，避免客户端收到 Gin 默认的纯文本 404
+	router.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found", "code": "ROUTE_NOT_FOUND"})
+	})
+
+	// 路径存在但方法不匹配时返回 405，Gin 会设置 Allow 响应头，这里同时放入响应体
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(func(c *gin.Context) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{
+			"error":           "Method not allowed",
+			"code":            "METHOD_NOT_ALLOWED",
+			"allowed_methods": strings.Split(c.Writer.Header().Get("Allow"), ", "),
+		})
+	})
+
+	// 收到 SIGINT/SIGTERM 时取消 ctx，触发优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动后台任务，周期性重新计算热门电影和类型卡片缓存，间隔由 CACHE_REFRESH_INTERVAL 配置（默认 10 分钟）
+	refreshInterval := 10 * time.Minute
+	if value, err := time.ParseDuration(os.Getenv("CACHE_REFRESH_INTERVAL")); err == nil && value > 0 {
+		refreshInterval = value
+	}
+	schedulerDone := controller.StartCacheScheduler(ctx, client, refreshInterval)
+
 	// 启动服务器，监听 8080 端口
-	if err := router.Run(":8080"); err != nil {
-		fmt.Println("Failed to start server:", err)
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("Failed to start server:", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+
+	// 等待进行中的请求处理完成，最多 10 秒
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
 	}
+
+	// 等待后台任务退出后再断开数据库连接
+	<-schedulerDone
 }
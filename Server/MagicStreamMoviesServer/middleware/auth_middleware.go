@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -47,6 +50,13 @@ func AuthMiddleware() gin.HandlerFunc {
 		// - 未过期
 		// - 未被篡改
 		claims, err := utils.ValidateToken(token)
+		if errors.Is(err, utils.ErrTokenCheckUnavailable) {
+			// 无法查询令牌版本（如数据库暂时不可用）不代表令牌无效，返回 503 让客户端重试而不是重新登录
+			log.Printf("error verifying token version: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token, please retry", "code": "TOKEN_CHECK_UNAVAILABLE"})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			// 如果令牌验证失败（过期、无效、被篡改等）
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
@@ -56,8 +66,8 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 步骤 4：将用户信息存储到请求上下文中
 		// 这样后续的处理器就可以直接获取用户信息，无需重复验证
-		c.Set("userID", claims.UserID) // 存储用户ID，用于数据查询和权限控制
-		c.Set("role", claims.Role)     // 存储用户角色，用于权限判断
+		c.Set("userID", claims.UserID)                   // 存储用户ID，用于数据查询和权限控制
+		c.Set("role", models.NormalizeRole(claims.Role)) // 存储用户角色（统一为大写），用于权限判断
 
 		// 步骤 5：继续执行下一个处理器
 		// 只有通过所有验证的请求才能到达这里
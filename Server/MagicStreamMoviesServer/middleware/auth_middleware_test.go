@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthMiddlewareTokenVersionResults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalKey := utils.SECRET_KEY
+	utils.SECRET_KEY = "test-access-secret"
+	t.Cleanup(func() {
+		utils.SECRET_KEY = originalKey
+		utils.SetTokenVersionLookup(nil)
+	})
+
+	token, _, err := utils.GenerateAllTokens("a@example.com", "Alice", "Example", "USER", "u1", utils.NewTokenID(), 1)
+	if err != nil {
+		t.Fatalf("GenerateAllTokens: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		lookup utils.TokenVersionLookup
+		want   int
+	}{
+		{"current token", func(ctx context.Context, userId string) (int, error) { return 1, nil }, http.StatusOK},
+		{"revoked token", func(ctx context.Context, userId string) (int, error) { return 2, nil }, http.StatusUnauthorized},
+		{"database unavailable", func(ctx context.Context, userId string) (int, error) {
+			return 0, errors.New("server selection timeout")
+		}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utils.SetTokenVersionLookup(tt.lookup)
+
+			router := gin.New()
+			router.GET("/me", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonMediaType 写接口默认要求的请求体类型
+const jsonMediaType = "application/json"
+
+// ContentTypeMiddleware 写请求的 Content-Type 检查中间件
+// 带请求体的 POST/PUT/PATCH 必须声明 application/json（允许附带 charset=utf-8），否则返回 415，
+// 避免客户端漏掉请求头时 ShouldBindJSON 报出令人困惑的解析错误；没有请求体的写请求（如 /logout）不受影响。
+// extraTypes 按路由模板（c.FullPath()）配置额外允许的类型，例如排名导入还接受 text/csv。
+// 设置环境变量 ENFORCE_JSON_CONTENT_TYPE=false 可关闭检查
+func ContentTypeMiddleware(extraTypes map[string][]string) gin.HandlerFunc {
+	enabled := os.Getenv("ENFORCE_JSON_CONTENT_TYPE") != "false"
+	if !enabled {
+		log.Println("Warning: JSON content type enforcement disabled")
+	}
+
+	return func(c *gin.Context) {
+		if !enabled || !hasRequestBody(c.Request) {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		allowed := append([]string{jsonMediaType}, extraTypes[c.FullPath()]...)
+		mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || !containsFold(allowed, mediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "Unsupported Content-Type, send the request body with Content-Type: application/json",
+				"code":    "UNSUPPORTED_MEDIA_TYPE",
+				"allowed": allowed,
+			})
+			c.Abort()
+			return
+		}
+		if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "utf8") {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Unsupported charset, only utf-8 is accepted",
+				"code":  "UNSUPPORTED_CHARSET",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasRequestBody 判断请求是否携带请求体，长度未知（分块传输）时视为有请求体
+func hasRequestBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// containsFold 判断 values 中是否有与 value 忽略大小写相同的项
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireFeature 功能开关关闭时返回 404 的中间件，关闭的功能对客户端表现为接口不存在
+func RequireFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !features.Enabled(name) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feature is not enabled", "code": "FEATURE_DISABLED", "feature": name})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
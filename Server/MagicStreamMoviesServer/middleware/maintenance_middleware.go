@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMode 维护模式
+type MaintenanceMode string
+
+const (
+	MaintenanceOff      MaintenanceMode = "off"       // 正常服务
+	MaintenanceReadOnly MaintenanceMode = "read_only" // 只读：拒绝所有写请求
+	MaintenanceFull     MaintenanceMode = "full"      // 完全停机：拒绝所有请求
+)
+
+// MaintenanceTogglePath 管理员切换维护模式的接口路径，始终放行，避免把自己锁在外面
+const MaintenanceTogglePath = "/admin/maintenance"
+
+var (
+	maintenanceMu   sync.RWMutex
+	maintenanceMode = MaintenanceOff
+)
+
+// ParseMaintenanceMode 解析维护模式字符串，空字符串视为关闭
+func ParseMaintenanceMode(value string) (MaintenanceMode, error) {
+	switch MaintenanceMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", MaintenanceOff:
+		return MaintenanceOff, nil
+	case MaintenanceReadOnly:
+		return MaintenanceReadOnly, nil
+	case MaintenanceFull:
+		return MaintenanceFull, nil
+	}
+	return "", errors.New("invalid maintenance mode, expected one of: off, read_only, full")
+}
+
+// GetMaintenanceMode 返回当前的维护模式
+func GetMaintenanceMode() MaintenanceMode {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return maintenanceMode
+}
+
+// SetMaintenanceMode 切换维护模式（由管理员接口调用）
+func SetMaintenanceMode(mode MaintenanceMode) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	maintenanceMode = mode
+}
+
+// MaintenanceMiddleware 维护模式中间件
+// read_only 模式下拒绝 POST/PUT/PATCH/DELETE，full 模式下拒绝所有请求，均返回 503 和 Retry-After
+// 初始模式来自环境变量 MAINTENANCE_MODE，运行时可由管理员通过接口切换
+func MaintenanceMiddleware() gin.HandlerFunc {
+	initialMode, err := ParseMaintenanceMode(os.Getenv("MAINTENANCE_MODE"))
+	if err != nil {
+		log.Printf("Warning: %v, maintenance mode disabled", err)
+		initialMode = MaintenanceOff
+	}
+	SetMaintenanceMode(initialMode)
+
+	// Retry-After 秒数，默认 5 分钟
+	retryAfter := envInt("MAINTENANCE_RETRY_AFTER", 300)
+
+	// 默认允许管理员在维护期间继续操作，以便重新开放前进行验证
+	adminBypass := os.Getenv("MAINTENANCE_ADMIN_BYPASS") != "false"
+
+	return func(c *gin.Context) {
+		mode := GetMaintenanceMode()
+		if mode == MaintenanceOff || c.FullPath() == MaintenanceTogglePath {
+			c.Next()
+			return
+		}
+
+		if mode == MaintenanceReadOnly && !isWriteMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if claims := optionalClaims(c); adminBypass && claims != nil && models.IsAdminRole(claims.Role) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is under maintenance", "mode": mode})
+		c.Abort()
+	}
+}
+
+// isWriteMethod 判断请求方法是否会修改数据
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// optionalClaims 尝试从 Cookie 中解析访问令牌，令牌缺失或无效时返回 nil
+// 用于运行在认证中间件之前、需要识别用户但不强制登录的中间件
+func optionalClaims(c *gin.Context) *utils.SignedDetails {
+	token, err := c.Cookie("access_token")
+	if err != nil || token == "" {
+		return nil
+	}
+	claims, err := utils.ValidateToken(token)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter 限流器接口
+// 当前提供内存实现，以后可以替换为 Redis 等多实例共享的实现
+type RateLimiter interface {
+	// Allow 记录一次请求，返回是否放行以及当前窗口的额度使用情况
+	Allow(key string) RateLimitDecision
+	// Release 归还一次 Allow 占用的额度，用于预先占用额度、事后发现不需要计数的情况
+	Release(key string)
+	// Reset 清除 key 的计数
+	Reset(key string)
+}
+
+// RateLimitDecision 一次限流判断的结果
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int       // 每个窗口的总额度
+	Remaining int       // 本次请求之后窗口内剩余的额度
+	ResetAt   time.Time // 当前窗口结束、额度恢复的时间
+}
+
+// rateLimitWindow 某个 key 在当前时间窗口内的请求计数
+type rateLimitWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// memoryRateLimiter 基于内存的固定窗口限流器
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*rateLimitWindow
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimiter 创建内存限流器：每个 key 在 window 时间内最多允许 limit 次请求
+func NewMemoryRateLimiter(limit int, window time.Duration) RateLimiter {
+	return &memoryRateLimiter{
+		limit:     limit,
+		window:    window,
+		windows:   make(map[string]*rateLimitWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *memoryRateLimiter) Allow(key string) RateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateLimitWindow{resetAt: now.Add(l.window)}
+		l.windows[key] = w
+	}
+
+	decision := RateLimitDecision{Limit: l.limit, ResetAt: w.resetAt}
+	if w.count >= l.limit {
+		return decision
+	}
+	w.count++
+	decision.Allowed = true
+	decision.Remaining = l.limit - w.count
+	return decision
+}
+
+func (l *memoryRateLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 窗口已经结束时计数会被重置，无需归还
+	if w, ok := l.windows[key]; ok && time.Now().Before(w.resetAt) && w.count > 0 {
+		w.count--
+	}
+}
+
+func (l *memoryRateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.windows, key)
+}
+
+// sweep 定期清理已过期的窗口，防止 map 无限增长
+func (l *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, w := range l.windows {
+		if !now.Before(w.resetAt) {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// BrowsingRateLimitMiddleware 公开浏览接口的两级限流中间件
+// 匿名请求按 IP 限流，已登录用户按用户 ID 限流且额度更高，避免爬虫抓取的同时不影响正常浏览
+// 每分钟额度分别由 ANON_RATE_LIMIT_PER_MINUTE（默认 60）和 AUTH_RATE_LIMIT_PER_MINUTE（默认 300）配置
+// 每个响应都带有 X-RateLimit-Limit、X-RateLimit-Remaining 和 X-RateLimit-Reset（额度恢复的 Unix 时间戳），
+// 客户端可以据此在触发 429 之前主动降低请求频率
+func BrowsingRateLimitMiddleware() gin.HandlerFunc {
+	anonLimiter := NewMemoryRateLimiter(envInt("ANON_RATE_LIMIT_PER_MINUTE", 60), time.Minute)
+	authLimiter := NewMemoryRateLimiter(envInt("AUTH_RATE_LIMIT_PER_MINUTE", 300), time.Minute)
+
+	return func(c *gin.Context) {
+		limiter, key := anonLimiter, "ip:"+c.ClientIP()
+		if userId, ok := c.Get("userID"); ok {
+			limiter, key = authLimiter, "user:"+userId.(string)
+		} else if claims := optionalClaims(c); claims != nil {
+			limiter, key = authLimiter, "user:"+claims.UserID
+		}
+
+		decision := limiter.Allow(key)
+		setRateLimitHeaders(c, decision)
+		if !decision.Allowed {
+			abortTooManyRequests(c, decision, "Too many requests, please try again later")
+			return
+		}
+		c.Next()
+	}
+}
+
+// LoginRateLimitMiddleware 登录接口的失败次数限制中间件，防止暴力破解密码
+// 只统计失败的登录（处理器返回 401），同一邮箱在窗口内失败 LOGIN_MAX_FAILED_ATTEMPTS 次（默认 5）、
+// 或同一 IP 失败 LOGIN_IP_MAX_FAILED_ATTEMPTS 次（默认 20）后，窗口结束前的登录请求直接返回 429 和 Retry-After；
+// 窗口长度由 LOGIN_RATE_LIMIT_WINDOW_SECONDS 配置（默认 60 秒）
+// 每个请求在调用处理器之前先占用一次额度，并发的猜测请求不能越过限制；处理器没有返回 401 时再归还额度
+// 登录成功后清除该邮箱的失败计数；IP 的计数不清除，避免攻击者用自己的账号登录来重置计数
+func LoginRateLimitMiddleware() gin.HandlerFunc {
+	window := time.Duration(envInt("LOGIN_RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second
+	emailLimiter := NewMemoryRateLimiter(envInt("LOGIN_MAX_FAILED_ATTEMPTS", 5), window)
+	ipLimiter := NewMemoryRateLimiter(envInt("LOGIN_IP_MAX_FAILED_ATTEMPTS", 20), window)
+
+	return func(c *gin.Context) {
+		ipKey := "ip:" + c.ClientIP()
+		emailKey := ""
+		if email := loginEmail(c); email != "" {
+			emailKey = "email:" + email
+		}
+
+		if decision := ipLimiter.Allow(ipKey); !decision.Allowed {
+			abortTooManyRequests(c, decision, "Too many failed login attempts, please try again later")
+			return
+		}
+		if emailKey != "" {
+			if decision := emailLimiter.Allow(emailKey); !decision.Allowed {
+				ipLimiter.Release(ipKey)
+				abortTooManyRequests(c, decision, "Too many failed login attempts, please try again later")
+				return
+			}
+		}
+
+		c.Next()
+
+		// 401 是失败的登录，保留占用的额度
+		status := c.Writer.Status()
+		if status == http.StatusUnauthorized {
+			return
+		}
+		ipLimiter.Release(ipKey)
+		if emailKey == "" {
+			return
+		}
+		if status == http.StatusOK {
+			emailLimiter.Reset(emailKey)
+		} else {
+			emailLimiter.Release(emailKey)
+		}
+	}
+}
+
+// loginEmail 读取登录请求体中的邮箱（转为小写），并恢复请求体供处理器绑定；读取失败时返回空字符串
+func loginEmail(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+// abortTooManyRequests 写入带 Retry-After 的 429 响应并中止请求
+func abortTooManyRequests(c *gin.Context, decision RateLimitDecision, message string) {
+	retryAfter := time.Until(decision.ResetAt)
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+	c.Abort()
+}
+
+// setRateLimitHeaders 写入额度相关的响应头
+// 在 c.Next() 之前写入，处理器返回任何状态码时都会带上
+func setRateLimitHeaders(c *gin.Context, decision RateLimitDecision) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(decision.ResetAt.Unix(), 10))
+}
+
+// envInt 读取正整数环境变量，未设置或非法时使用默认值
+func envInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLoginRouter 注册带失败次数限制的登录路由，handler 决定登录结果
+func newLoginRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", LoginRateLimitMiddleware(), handler)
+	return router
+}
+
+func postLogin(router http.Handler, email, password string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.7:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// passwordHandler 密码为 "correct" 时返回 200，为 "malformed" 时返回 400，否则返回 401
+func passwordHandler(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	switch req.Password {
+	case "correct":
+		c.Status(http.StatusOK)
+	case "malformed":
+		c.Status(http.StatusBadRequest)
+	default:
+		c.Status(http.StatusUnauthorized)
+	}
+}
+
+func TestLoginRateLimitLocksOutEmailAfterFailures(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILED_ATTEMPTS", "3")
+	router := newLoginRouter(passwordHandler)
+
+	for i := 0; i < 3; i++ {
+		if w := postLogin(router, "alice@example.com", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want 401", i+1, w.Code)
+		}
+	}
+
+	w := postLogin(router, "Alice@Example.com", "correct")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt after lockout status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After header")
+	}
+
+	// 其他邮箱不受影响
+	if w := postLogin(router, "bob@example.com", "correct"); w.Code != http.StatusOK {
+		t.Errorf("other email status = %d, want 200", w.Code)
+	}
+}
+
+func TestLoginRateLimitOnlyCountsFailedLogins(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILED_ATTEMPTS", "2")
+	router := newLoginRouter(passwordHandler)
+
+	for i := 0; i < 5; i++ {
+		if w := postLogin(router, "alice@example.com", "malformed"); w.Code != http.StatusBadRequest {
+			t.Fatalf("malformed attempt %d status = %d, want 400", i+1, w.Code)
+		}
+	}
+
+	// 成功登录清除失败计数
+	postLogin(router, "alice@example.com", "wrong")
+	postLogin(router, "alice@example.com", "correct")
+	for i := 0; i < 2; i++ {
+		if w := postLogin(router, "alice@example.com", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d after successful login status = %d, want 401", i+1, w.Code)
+		}
+	}
+	if w := postLogin(router, "alice@example.com", "wrong"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+}
+
+func TestLoginRateLimitHoldsUnderConcurrentGuesses(t *testing.T) {
+	const limit, requests = 3, 10
+	t.Setenv("LOGIN_MAX_FAILED_ATTEMPTS", "3")
+
+	// 处理器在放行之前一直阻塞，所有请求同时处于处理中
+	var handled atomic.Int32
+	release := make(chan struct{})
+	router := newLoginRouter(func(c *gin.Context) {
+		handled.Add(1)
+		<-release
+		c.Status(http.StatusUnauthorized)
+	})
+
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- postLogin(router, "alice@example.com", "guess").Code
+		}()
+	}
+
+	// 超出额度的请求不会进入处理器，应立即返回 429
+	deadline := time.After(5 * time.Second)
+	for rejected := 0; rejected < requests-limit; rejected++ {
+		select {
+		case code := <-codes:
+			if code != http.StatusTooManyRequests {
+				t.Fatalf("early response status = %d, want 429", code)
+			}
+		case <-deadline:
+			t.Fatalf("only %d requests rejected while guesses were in flight, want %d", rejected, requests-limit)
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if got := handled.Load(); got != limit {
+		t.Errorf("handler ran %d times, want %d", got, limit)
+	}
+}
+
+func TestMemoryRateLimiterRelease(t *testing.T) {
+	limiter := NewMemoryRateLimiter(1, time.Minute)
+	if !limiter.Allow("k").Allowed {
+		t.Fatal("first request denied")
+	}
+	if limiter.Allow("k").Allowed {
+		t.Fatal("second request allowed over the limit")
+	}
+	limiter.Release("k")
+	if !limiter.Allow("k").Allowed {
+		t.Error("request denied after releasing the reserved slot")
+	}
+}
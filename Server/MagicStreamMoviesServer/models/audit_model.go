@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// 审计日志的操作类型
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	AuditActionRank   = "rank"
+)
+
+// AuditEntry 一条审计日志，记录谁在什么时候对哪部电影做了什么修改
+type AuditEntry struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Action       string        `bson:"action" json:"action"`
+	ActorID      string        `bson:"actor_id" json:"actor_id"`
+	TargetImdbID string        `bson:"target_imdb_id" json:"target_imdb_id"`
+	Details      bson.M        `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt    time.Time     `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// FailedRanking 一次调用 AI 失败的排名请求，等待管理员重试
+// 每部电影最多一条，记录最近一次提交的评论
+type FailedRanking struct {
+	ImdbID        string    `bson:"imdb_id" json:"imdb_id"`
+	AdminReview   string    `bson:"admin_review" json:"admin_review"`
+	LastError     string    `bson:"last_error" json:"last_error"`
+	Attempts      int       `bson:"attempts" json:"attempts"`
+	ActorID       string    `bson:"actor_id" json:"actor_id"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+	LastAttemptAt time.Time `bson:"last_attempt_at" json:"last_attempt_at"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -9,18 +11,146 @@ type Genre struct {
 	GenreName string `bson:"genre_name" json:"genre_name" validate:"required,min=2,max=100"`
 }
 
+// DefaultUnrankedValue 默认的"未排名"哨兵值
+// 排名值等于该值的等级表示电影尚未排名，不会作为候选项提供给 AI，推荐排序时视为最差
+// 可通过环境变量 UNRANKED_RANKING_VALUE 覆盖
+const DefaultUnrankedValue = 999
+
 type Ranking struct {
 	RankingValue int    `bson:"ranking_value" json:"ranking_value" validate:"required"`
 	RankingName  string `bson:"ranking_name" json:"ranking_name" validate:"required"`
 }
 
+// FeaturedGenre 类型卡片：类型及其排名最高的电影
+type FeaturedGenre struct {
+	GenreID    int    `bson:"genre_id" json:"genre_id"`
+	GenreName  string `bson:"genre_name" json:"genre_name"`
+	ImdbID     string `bson:"imdb_id" json:"imdb_id"`
+	Title      string `bson:"title" json:"title"`
+	PosterPath string `bson:"poster_path" json:"poster_path"`
+}
+
+// CastMember 演员及其饰演的角色
+type CastMember struct {
+	Name string `bson:"name" json:"name" validate:"required,max=200"`
+	Role string `bson:"role" json:"role" validate:"max=200"`
+}
+
 type Movie struct {
 	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	ImdbID      string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
 	Title       string        `bson:"title" json:"title" validate:"required,min=2,max=500"`
+	Description string        `bson:"description" json:"description"`
 	PosterPath  string        `bson:"poster_path" json:"poster_path" validate:"required,url"`
 	YouTubeID   string        `bson:"youtube_id" json:"youtube_id" validate:"required"`
 	Genre       []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
+	Tags        []string      `bson:"tags,omitempty" json:"tags,omitempty"`
+	Cast        []CastMember  `bson:"cast,omitempty" json:"cast,omitempty" validate:"omitempty,max=200,dive"`
+	Directors   []string      `bson:"directors,omitempty" json:"directors,omitempty" validate:"omitempty,max=50,dive,required,max=200"`
 	AdminReview string        `bson:"admin_review" json:"admin_review"`
 	Ranking     Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time     `bson:"updated_at" json:"updated_at"`
+
+	// 演员和导演的规范化姓名（小写、合并空白），由服务端根据 cast 和 directors 维护，用于不区分大小写的过滤和索引
+	CastKeys     []string `bson:"cast_keys,omitempty" json:"-"`
+	DirectorKeys []string `bson:"director_keys,omitempty" json:"-"`
+
+	// Locked 锁定的电影为人工定稿的条目，拒绝编辑、重新排名和批量覆盖，只能通过锁定接口修改
+	Locked bool `bson:"locked,omitempty" json:"locked"`
+
+	// ReleaseDate 上线日期，晚于当前时间的电影处于"即将上线"状态，到期后自动进入普通目录；为空表示已上线
+	ReleaseDate *time.Time `bson:"release_date,omitempty" json:"release_date,omitempty"`
+
+	// 用户评分聚合字段，由评论接口原子维护，不接受客户端写入
+	UserRatingAvg   float64 `bson:"user_rating_avg" json:"user_rating_avg"`
+	UserRatingCount int     `bson:"user_rating_count" json:"user_rating_count"`
+	UserRatingSum   int     `bson:"user_rating_sum" json:"-"`
+}
+
+// StructuredMovie /api/v1 路由返回的结构化电影，将原始元数据、管理员编辑字段和计算得到的统计字段分开
+// 根路由在弃用期间仍然返回扁平的 Movie；Editorial 为 nil 时（非管理员请求）不输出 editorial 分组
+type StructuredMovie struct {
+	ID        bson.ObjectID   `json:"_id,omitempty"`
+	Metadata  MovieMetadata   `json:"metadata"`
+	Editorial *MovieEditorial `json:"editorial,omitempty"`
+	Stats     MovieStats      `json:"stats"`
+}
+
+// MovieMetadata 电影的原始元数据
+type MovieMetadata struct {
+	ImdbID      string       `json:"imdb_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	PosterPath  string       `json:"poster_path"`
+	YouTubeID   string       `json:"youtube_id"`
+	Genre       []Genre      `json:"genre"`
+	Tags        []string     `json:"tags,omitempty"`
+	Cast        []CastMember `json:"cast,omitempty"`
+	Directors   []string     `json:"directors,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	ReleaseDate *time.Time   `json:"release_date,omitempty"`
+}
+
+// MovieEditorial 管理员维护的编辑字段
+type MovieEditorial struct {
+	AdminReview string  `json:"admin_review"`
+	Ranking     Ranking `json:"ranking"`
+	Locked      bool    `json:"locked"`
+}
+
+// MovieStats 由服务端计算维护的统计字段
+type MovieStats struct {
+	UserRatingAvg   float64 `json:"user_rating_avg"`
+	UserRatingCount int     `json:"user_rating_count"`
+}
+
+// Structured 将扁平的 Movie 转换为结构化表示
+func (m Movie) Structured() StructuredMovie {
+	return StructuredMovie{
+		ID: m.ID,
+		Metadata: MovieMetadata{
+			ImdbID:      m.ImdbID,
+			Title:       m.Title,
+			Description: m.Description,
+			PosterPath:  m.PosterPath,
+			YouTubeID:   m.YouTubeID,
+			Genre:       m.Genre,
+			Tags:        m.Tags,
+			Cast:        m.Cast,
+			Directors:   m.Directors,
+			CreatedAt:   m.CreatedAt,
+			UpdatedAt:   m.UpdatedAt,
+			ReleaseDate: m.ReleaseDate,
+		},
+		Editorial: &MovieEditorial{
+			AdminReview: m.AdminReview,
+			Ranking:     m.Ranking,
+			Locked:      m.Locked,
+		},
+		Stats: MovieStats{
+			UserRatingAvg:   m.UserRatingAvg,
+			UserRatingCount: m.UserRatingCount,
+		},
+	}
+}
+
+// MovieUpdate 部分更新电影的请求体，未提供的字段保持不变
+// 排名和用户评分聚合字段由专门的接口维护，不能通过此接口修改
+type MovieUpdate struct {
+	// ImdbID 是查找电影的键，不能修改；只用于检测客户端是否试图修改它
+	ImdbID *string `json:"imdb_id"`
+
+	Title       *string  `json:"title" validate:"omitempty,min=2,max=500"`
+	Description *string  `json:"description"`
+	PosterPath  *string  `json:"poster_path" validate:"omitempty,url"`
+	YouTubeID   *string  `json:"youtube_id" validate:"omitempty,min=1"`
+	Genre       *[]Genre `json:"genre" validate:"omitempty,min=1,dive"`
+
+	// 演员和导演整体替换，传入空数组表示清空
+	Cast      *[]CastMember `json:"cast" validate:"omitempty,max=200,dive"`
+	Directors *[]string     `json:"directors" validate:"omitempty,max=50,dive,required,max=200"`
+
+	ReleaseDate *time.Time `json:"release_date"`
 }
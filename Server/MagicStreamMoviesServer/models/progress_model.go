@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WatchProgress 用户观看某部电影的播放进度，以 user_id + imdb_id 唯一确定
+type WatchProgress struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID          string        `bson:"user_id" json:"user_id"`
+	ImdbID          string        `bson:"imdb_id" json:"imdb_id"`
+	PositionSeconds int           `bson:"position_seconds" json:"position_seconds" validate:"min=0"`
+	DurationSeconds int           `bson:"duration_seconds" json:"duration_seconds" validate:"required,min=1"`
+	Completed       bool          `bson:"completed" json:"completed"`
+	UpdatedAt       time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// ContinueWatchingItem "继续观看"列表中的一项：电影信息加上播放进度
+type ContinueWatchingItem struct {
+	Movie           Movie     `bson:"movie" json:"movie"`
+	PositionSeconds int       `bson:"position_seconds" json:"position_seconds"`
+	DurationSeconds int       `bson:"duration_seconds" json:"duration_seconds"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RatingChange 用户评分的一次变更记录
+type RatingChange struct {
+	Rating    int       `bson:"rating" json:"rating"`
+	ChangedAt time.Time `bson:"changed_at" json:"changed_at"`
+}
+
+type Review struct {
+	ID            bson.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID        string         `bson:"user_id" json:"user_id"`
+	ImdbID        string         `bson:"imdb_id" json:"imdb_id"`
+	Rating        int            `bson:"rating" json:"rating" validate:"required,min=1,max=5"`
+	Comment       string         `bson:"comment" json:"comment" validate:"max=2000"`
+	RatingHistory []RatingChange `bson:"rating_history" json:"rating_history"`
+	HelpfulCount  int            `bson:"helpful_count" json:"helpful_count"`
+	HelpfulVoters []string       `bson:"helpful_voters,omitempty" json:"-"`
+	CreatedAt     time.Time      `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time      `bson:"updated_at" json:"updated_at"`
+}
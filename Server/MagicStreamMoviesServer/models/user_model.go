@@ -1,11 +1,37 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// 允许的用户角色，存储和比较时统一使用大写
+const (
+	RoleAdmin = "ADMIN"
+	RoleUser  = "USER"
+)
+
+// NormalizeRole 去除角色两端空白并转换为大写，避免 "admin" 与 "ADMIN" 被当作不同角色
+func NormalizeRole(role string) string {
+	return strings.ToUpper(strings.TrimSpace(role))
+}
+
+// IsValidRole 判断角色是否在允许的集合内
+func IsValidRole(role string) bool {
+	switch NormalizeRole(role) {
+	case RoleAdmin, RoleUser:
+		return true
+	}
+	return false
+}
+
+// IsAdminRole 判断角色是否为管理员，所有管理员权限检查都应使用此函数
+func IsAdminRole(role string) bool {
+	return NormalizeRole(role) == RoleAdmin
+}
+
 type User struct {
 	ID              bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	UserID          string        `bson:"user_id" json:"user_id"`
@@ -16,9 +42,25 @@ type User struct {
 	Role            string        `bson:"role" json:"role" validate:"oneof=ADMIN USER"`
 	CreatedAt       time.Time     `bson:"created_at" json:"created_at"`
 	UpdatedAt       time.Time     `bson:"updated_at" json:"updated_at"`
-	Token           string        `bson:"token" json:"token"`
-	RefreshToken    string        `bson:"refresh_token" json:"refresh_token"`
 	FavouriteGenres []Genre       `bson:"favourite_genres" json:"favourite_genres" validate:"required,dive"`
+
+	// PasswordChangedAt 最近一次修改密码的时间
+	PasswordChangedAt *time.Time `bson:"password_changed_at,omitempty" json:"-"`
+
+	// TokenVersion 令牌版本，写入令牌声明；登出和修改密码时递增，使此前签发的所有令牌失效
+	TokenVersion int `bson:"token_version,omitempty" json:"-"`
+
+	// RefreshTokenIDs 当前有效的刷新令牌标识（jti），每个登录会话一个，刷新时原地替换为新的标识
+	RefreshTokenIDs []string `bson:"refresh_token_ids,omitempty" json:"-"`
+
+	// RotatedRefreshTokenIDs 最近已被轮换掉的刷新令牌标识，再次出示说明令牌被重放；
+	// 因会话数超限被挤掉的标识不在其中，只按会话过期处理
+	RotatedRefreshTokenIDs []string `bson:"rotated_refresh_token_ids,omitempty" json:"-"`
+}
+
+// RoleChange 管理员修改用户角色的请求体
+type RoleChange struct {
+	Role string `json:"role" validate:"required"`
 }
 
 type UserLogin struct {
@@ -26,13 +68,27 @@ type UserLogin struct {
 	Password string `json:"password" validate:"required,min=8"`
 }
 
+// PasswordChange 修改密码的请求体，bcrypt 只使用前 72 字节，因此限制新密码长度
+type PasswordChange struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// FavouriteGenresUpdate 更新喜爱类型的请求体
+type FavouriteGenresUpdate struct {
+	FavouriteGenres []Genre `json:"favourite_genres" validate:"required,dive"`
+}
+
 type UserResponse struct {
 	UserID          string  `json:"user_id"`
 	FirstName       string  `json:"first_name"`
 	LastName        string  `json:"last_name"`
 	Email           string  `json:"email"`
 	Role            string  `json:"role"`
-	Token           string  `json:"token"`
-	RefreshToken    string  `json:"refresh_token"`
+	Token           string  `json:"token,omitempty"`
+	RefreshToken    string  `json:"refresh_token,omitempty"`
 	FavouriteGenres []Genre `json:"favourite_genres"`
+
+	// GenreBreakdown 用户评论过的电影按类型统计的数量，仅在请求 ?include=taste 时返回
+	GenreBreakdown map[string]int `json:"genre_breakdown,omitempty"`
 }
@@ -2,16 +2,67 @@ package routes
 
 import (
 	controller "github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/controllers"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
 	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func SetupProtectedRoutes(router *gin.Engine, client *mongo.Client) {
-	router.Use(middleware.AuthMiddleware())
+	// 认证中间件只挂在受保护路由组上，未匹配的路由（NoRoute/NoMethod）不经过认证，匿名请求也能得到 404/405
+	protected := router.Group("", middleware.AuthMiddleware())
 
-	router.GET("/movie/:imdb_id", controller.GetMovie(client))
-	router.POST("/addmovie", controller.AddMovie(client))
-	router.GET("/recommendedmovies", controller.GetRecommendedMovies(client))
-	router.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(client))
+	recommendationsFeature := middleware.RequireFeature(features.Recommendations)
+	reviewsFeature := middleware.RequireFeature(features.UserReviews)
+	progressFeature := middleware.RequireFeature(features.WatchProgress)
+
+	protected.GET("/movie/:imdb_id", controller.GetMovie(client))
+	protected.PATCH("/movie/:imdb_id", controller.UpdateMovie(client))
+	protected.DELETE("/movie/:imdb_id", controller.DeleteMovie(client))
+	protected.POST("/movie/:imdb_id/tags", controller.AddMovieTags(client))
+	protected.DELETE("/movie/:imdb_id/tags/:tag", controller.RemoveMovieTag(client))
+	protected.POST("/addmovie", controller.AddMovie(client))
+	protected.POST("/addmovies", controller.BulkAddMovies(client))
+	protected.POST("/movies/exists", controller.CheckMoviesExist(client))
+	protected.GET("/recommendedmovies", recommendationsFeature, controller.GetRecommendedMovies(client))
+	protected.GET("/recommendations/by-genre", recommendationsFeature, controller.GetRecommendationsByGenre(client))
+	protected.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(client))
+	protected.PATCH("/movie/:imdb_id/ranking", controller.SetMovieRanking(client))
+	protected.PUT("/movie/:imdb_id/lock", controller.SetMovieLock(client))
+	protected.POST("/movie/:imdb_id/review", reviewsFeature, controller.SubmitUserReview(client))
+	protected.GET("/movie/:imdb_id/review-histogram", reviewsFeature, controller.GetReviewHistogram(client))
+	protected.GET("/movie/:imdb_id/reviews", reviewsFeature, controller.GetMovieReviews(client))
+	protected.POST("/review/:id/helpful", reviewsFeature, controller.MarkReviewHelpful(client))
+	protected.GET("/movie/:imdb_id/audit", controller.GetMovieAudit(client))
+	protected.GET("/audit", controller.ListAuditEntries(client))
+	protected.PUT("/movie/:imdb_id/progress", progressFeature, controller.UpdateWatchProgress(client))
+	protected.GET("/continue-watching", progressFeature, controller.GetContinueWatching(client))
+	protected.GET("/me", controller.GetCurrentUser(client))
+	protected.PATCH("/me/genres", controller.UpdateFavouriteGenres(client))
+	protected.POST("/me/password", controller.ChangePassword(client))
+	protected.GET(middleware.MaintenanceTogglePath, controller.GetMaintenanceStatus())
+	protected.PUT(middleware.MaintenanceTogglePath, controller.SetMaintenanceStatus())
+	protected.GET("/admin/users/search", controller.SearchUsersByEmail(client))
+	protected.DELETE("/admin/users/:id", controller.AdminPurgeUser(client))
+	protected.PUT("/admin/users/:id/role", controller.SetUserRole(client))
+	protected.GET("/admin/users/:id/recommendations", recommendationsFeature, controller.PreviewUserRecommendations(client))
+	protected.POST("/admin/users/migrate-tokens", controller.MigrateLegacyTokens(client))
+	protected.POST("/admin/rankings/import", controller.ImportRankings(client))
+	protected.GET("/admin/rankings/distribution", controller.GetRankingDistribution(client))
+	protected.GET("/admin/analytics", controller.GetCatalogAnalytics(client))
+	protected.POST("/admin/ratings/recompute", controller.StartRatingRecompute(client))
+	protected.GET("/admin/ratings/recompute", controller.GetRatingRecomputeStatus())
+	protected.POST("/admin/rankings/retry-failed", controller.RetryFailedRankings(client))
+	protected.POST("/admin/reviews/validate", controller.ValidateAdminReviews())
+	protected.GET("/admin/movies/duplicates", controller.FindDuplicateMovies(client))
+	protected.GET("/admin/movies/recent-changes", controller.GetRecentMovieChanges(client))
+	protected.GET("/admin/movies/export", controller.ExportMovies(client))
+	protected.GET("/admin/indexes", controller.ListIndexesHandler(client))
+	protected.POST("/admin/indexes/ensure", controller.EnsureIndexesHandler(client))
+	protected.GET("/admin/features", controller.GetFeatureFlags())
+
+	// v1 是受保护路由组的子组，同样经过认证中间件
+	v1 := protected.Group("/api/v1", controller.StructuredMovieResponses())
+	v1.GET("/movie/:imdb_id", controller.GetMovie(client))
+	v1.GET("/recommendedmovies", recommendationsFeature, controller.GetRecommendedMovies(client))
 }
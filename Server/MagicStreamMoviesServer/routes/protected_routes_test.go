@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// newTestRouter 注册受保护路由和 NoRoute 处理器，客户端不会真正连接数据库
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	router := gin.New()
+	SetupProtectedRoutes(router, client)
+	router.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found", "code": "ROUTE_NOT_FOUND"})
+	})
+	return router
+}
+
+func TestUnknownRouteIsNotFoundForAnonymousRequests(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/does-not-exist", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusNotFound, w.Body.String())
+	}
+}
+
+func TestProtectedRouteRequiresAuthentication(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusUnauthorized, w.Body.String())
+	}
+}
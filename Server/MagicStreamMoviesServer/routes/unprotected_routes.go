@@ -2,15 +2,36 @@ package routes
 
 import (
 	controller "github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/controllers"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/features"
+	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func SetupUnprotectedRoutes(router *gin.Engine, client *mongo.Client) {
+	browsingLimiter := middleware.BrowsingRateLimitMiddleware()
+	recommendationsFeature := middleware.RequireFeature(features.Recommendations)
+
+	router.GET("/status", controller.GetStatus(client))
 	router.POST("/register", controller.RegisterUser(client))
-	router.POST("/login", controller.LoginUser(client))
+	router.POST("/login", middleware.LoginRateLimitMiddleware(), controller.LoginUser(client))
 	router.POST("/logout", controller.LogoutHandler(client))
-	router.GET("/movies", controller.GetMovies(client))
-	router.GET("/genres", controller.GetGenre(client))
+	router.GET("/movies", browsingLimiter, controller.GetMovies(client))
+	router.GET("/movies/search", browsingLimiter, controller.GetMovieSearch(client))
+	router.GET("/movies/trending", browsingLimiter, controller.GetTrendingMovies(client))
+	router.GET("/movies/new-count", browsingLimiter, controller.GetNewMovieCount(client))
+	router.GET("/movies/coming-soon", browsingLimiter, controller.GetComingSoonMovies(client))
+	router.GET("/people/:name/movies", browsingLimiter, controller.GetPersonMovies(client))
+	router.GET("/genres", browsingLimiter, controller.GetGenre(client))
+	router.GET("/genres/featured", browsingLimiter, controller.GetFeaturedGenres(client))
+	router.GET("/genres/names", browsingLimiter, controller.GetGenreNames(client))
+	router.GET("/rankings", browsingLimiter, controller.ListRankings(client))
 	router.POST("/refresh", controller.RefreshTokenHandler(client))
+	router.POST("/auth/validate", controller.ValidateTokenHandler())
+	router.GET("/recommendations/preview", recommendationsFeature, browsingLimiter, controller.GetRecommendationsPreview(client))
+
+	// /api/v1 返回结构化电影（metadata/editorial/stats，editorial 仅返回给管理员），根路由在弃用期间保留扁平结构
+	v1 := router.Group("/api/v1", controller.StructuredMovieResponses())
+	v1.GET("/movies", browsingLimiter, controller.GetMovies(client))
+	v1.GET("/recommendations/preview", recommendationsFeature, browsingLimiter, controller.GetRecommendationsPreview(client))
 }
@@ -2,15 +2,16 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/KamisAyaka/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 // SignedDetails 结构体定义了 JWT Token 中包含的用户信息
@@ -22,26 +23,85 @@ type SignedDetails struct {
 	LastName             string // 用户姓氏
 	Role                 string // 用户角色 (ADMIN/USER)
 	UserID               string // 用户唯一标识符
+	TokenType            string `json:"token_type"`    // 令牌类型 (access/refresh)
+	TokenVersion         int    `json:"token_version"` // 签发时用户的令牌版本，登出或修改密码后版本递增，旧令牌随之失效
 	jwt.RegisteredClaims        // JWT 标准声明，包含过期时间、签发者等信息
 }
 
+// 令牌类型，写入 token_type 声明，防止访问令牌和刷新令牌被混用
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
 // 从环境变量获取 JWT 签名密钥
 // 这些密钥用于签名和验证 JWT Token，确保 Token 的安全性
 var SECRET_KEY string = os.Getenv("SECRET_KEY")                 // 访问令牌签名密钥
 var SECRET_REFRESH_KEY string = os.Getenv("SECRET_REFRESH_KEY") // 刷新令牌签名密钥
 
+// ErrTokenRevoked 令牌的版本已过期（用户登出或修改了密码），或令牌所属的用户已被删除
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// ErrTokenUserNotFound TokenVersionLookup 在用户不存在时返回的错误，令牌按已撤销处理
+var ErrTokenUserNotFound = errors.New("token user not found")
+
+// ErrTokenCheckUnavailable 查询令牌版本失败（如数据库不可用），无法判断令牌是否已撤销
+// 调用方应返回 503 而不是 401，避免客户端误以为需要重新登录
+var ErrTokenCheckUnavailable = errors.New("unable to verify token")
+
+// TokenVersionLookup 返回用户当前的令牌版本，用户不存在时返回 ErrTokenUserNotFound
+type TokenVersionLookup func(ctx context.Context, userId string) (int, error)
+
+// tokenVersionLookup 校验令牌时使用的版本查询函数，未设置时不检查版本
+var tokenVersionLookup TokenVersionLookup
+
+// SetTokenVersionLookup 设置令牌版本的查询函数，应在启动时、处理请求之前调用
+func SetTokenVersionLookup(lookup TokenVersionLookup) {
+	tokenVersionLookup = lookup
+}
+
+// checkTokenVersion 检查令牌中的版本与用户当前的令牌版本一致
+func checkTokenVersion(claims *SignedDetails) error {
+	if tokenVersionLookup == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	version, err := tokenVersionLookup(ctx, claims.UserID)
+	if errors.Is(err, ErrTokenUserNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTokenCheckUnavailable, err)
+	}
+	if version != claims.TokenVersion {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// NewTokenID 生成随机的令牌标识，写入刷新令牌的 jti 声明
+func NewTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // GenerateAllTokens 生成访问令牌和刷新令牌
 // 访问令牌：用于 API 请求的身份验证，有效期较短
-// 刷新令牌：用于获取新的访问令牌，有效期较长
-func GenerateAllTokens(email, firstName, lastName, role, userId string) (signedToken, signedRefreshToken string, err error) {
+// 刷新令牌：用于获取新的访问令牌，有效期较长；refreshTokenId 写入 jti 声明，服务端据此轮换和撤销刷新令牌
+// tokenVersion 为用户当前的令牌版本，两种令牌都会携带
+func GenerateAllTokens(email, firstName, lastName, role, userId, refreshTokenId string, tokenVersion int) (signedToken, signedRefreshToken string, err error) {
 	// 创建访问令牌的声明 (Claims)
 	// 声明包含用户信息和标准 JWT 字段
 	claims := &SignedDetails{
-		Email:     email,
-		FirstName: firstName,
-		LastName:  lastName,
-		Role:      role,
-		UserID:    userId,
+		Email:        email,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Role:         role,
+		UserID:       userId,
+		TokenType:    AccessTokenType,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "MagicStream",                                      // 签发者
 			IssuedAt:  jwt.NewNumericDate(time.Now()),                     // 签发时间
@@ -62,12 +122,15 @@ func GenerateAllTokens(email, firstName, lastName, role, userId string) (signedT
 	// 创建刷新令牌的声明
 	// 刷新令牌通常包含相同信息但有不同的过期时间
 	refreshClaims := &SignedDetails{
-		Email:     email,
-		FirstName: firstName,
-		LastName:  lastName,
-		Role:      role,
-		UserID:    userId,
+		Email:        email,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Role:         role,
+		UserID:       userId,
+		TokenType:    RefreshTokenType,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refreshTokenId,                                         // 令牌标识（jti）
 			Issuer:    "MagicStream",                                          // 签发者
 			IssuedAt:  jwt.NewNumericDate(time.Now()),                         // 签发时间
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)), // 过期时间：7天后
@@ -87,65 +150,46 @@ func GenerateAllTokens(email, firstName, lastName, role, userId string) (signedT
 	return signedToken, signedRefreshToken, nil
 }
 
-// UpdateAllTokens 更新用户数据库中的令牌信息
-// 当用户登录或刷新令牌时，需要将新的令牌保存到数据库中
-func UpdateAllTokens(userId, token, refreshToken string, client *mongo.Client) (err error) {
-	// 创建带超时的上下文，防止数据库操作超时
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel() // 确保资源被正确释放
-
-	// 格式化当前时间，用于更新 updated_at 字段
-	updateAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-	// 准备更新数据
-	// $set 操作符用于更新指定字段
-	updateData := bson.M{"$set": bson.M{
-		"token":         token,        // 新的访问令牌
-		"refresh_token": refreshToken, // 新的刷新令牌
-		"updated_at":    updateAt,     // 更新时间
-	}}
-	var userCollection *mongo.Collection = database.OpenCollection("users", client) // 用户集合
-
-	// 根据用户ID更新用户文档中的令牌信息
-	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, updateData)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 // GetAccessToken 从 HTTP 请求头中提取 JWT 访问令牌
 // 这个函数用于从标准的 Authorization 头中安全地提取 Bearer Token
 // 格式：Authorization: Bearer <JWT_TOKEN>
 func GetAccessToken(c *gin.Context) (string, error) {
 	// 从请求头中获取 Authorization 字段
 	// 这是 JWT 令牌的标准传输方式
-	// authHeader := c.Request.Header.Get("Authorization")
-
-	// // 检查是否存在 Authorization 头
-	// // 如果没有，说明请求未携带认证信息
-	// if authHeader == "" {
-	// 	return "", errors.New("authorization header is required")
-	// }
-
-	// // 从 "Bearer <token>" 格式中提取实际的 JWT 令牌
-	// // 去掉 "Bearer " 前缀（长度为 7 个字符）
-	// tokenString := authHeader[len("Bearer "):]
-
-	// // 验证提取的令牌是否为空
-	// // 防止 "Bearer " 后面没有实际令牌的情况
-	// if tokenString == "" {
-	// 	return "", errors.New("bearer token is required")
-	// }
-	tokenString, err := c.Cookie("access_token")
-	if err != nil {
-		return "", err
+	authHeader := c.Request.Header.Get("Authorization")
+
+	// 检查是否存在 Authorization 头
+	// 如果没有，说明请求未携带认证信息
+	if authHeader == "" {
+		return "", errors.New("authorization header is required")
+	}
+
+	// 只接受 "Bearer <token>" 格式，其它认证方案（如 Basic）直接拒绝
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		return "", errors.New("authorization header must use the Bearer scheme")
+	}
+	tokenString := strings.TrimSpace(authHeader[len(bearerPrefix):])
+
+	// 验证提取的令牌是否为空
+	// 防止 "Bearer " 后面没有实际令牌的情况
+	if tokenString == "" {
+		return "", errors.New("bearer token is required")
 	}
 
 	// 返回提取的 JWT 令牌字符串
 	return tokenString, nil
 }
 
+// GetRequestAccessToken 读取请求携带的访问令牌
+// 优先读取 access_token Cookie（浏览器），没有时读取 Authorization: Bearer 请求头（移动端、CLI 等）
+func GetRequestAccessToken(c *gin.Context) (string, error) {
+	if token, err := c.Cookie("access_token"); err == nil && token != "" {
+		return token, nil
+	}
+	return GetAccessToken(c)
+}
+
 // ValidateToken 验证 JWT 令牌的有效性
 // 这个函数用于验证从请求中提取的 JWT 令牌是否有效、未过期且未被篡改
 // 返回解析后的用户声明信息，如果验证失败则返回错误
@@ -163,7 +207,11 @@ func ValidateToken(tokenString string) (*SignedDetails, error) {
 
 	// 检查解析过程中是否出现错误
 	// 可能的错误：令牌格式错误、签名验证失败等
+	// 如果是误用了刷新令牌，返回更明确的错误
 	if err != nil {
+		if typeErr := checkTokenType(tokenString, AccessTokenType); typeErr != nil {
+			return nil, typeErr
+		}
 		return nil, err
 	}
 
@@ -181,6 +229,16 @@ func ValidateToken(tokenString string) (*SignedDetails, error) {
 		return nil, errors.New("token expired")
 	}
 
+	// 检查令牌类型，只接受访问令牌
+	if claims.TokenType != AccessTokenType {
+		return nil, errWrongTokenType(AccessTokenType, claims.TokenType)
+	}
+
+	// 检查令牌是否已被撤销（用户登出后，此前签发的令牌立即失效）
+	if err := checkTokenVersion(claims); err != nil {
+		return nil, err
+	}
+
 	// 如果所有验证都通过，返回解析后的用户声明信息
 	// 这些信息包含用户ID、邮箱、角色等，可用于后续的授权判断
 	return claims, nil
@@ -217,6 +275,9 @@ func ValidateRefreshToken(tokenString string) (*SignedDetails, error) {
 	})
 
 	if err != nil {
+		if typeErr := checkTokenType(tokenString, RefreshTokenType); typeErr != nil {
+			return nil, typeErr
+		}
 		return nil, err
 	}
 
@@ -228,5 +289,34 @@ func ValidateRefreshToken(tokenString string) (*SignedDetails, error) {
 		return nil, errors.New("refresh token has expired")
 	}
 
+	if claims.TokenType != RefreshTokenType {
+		return nil, errWrongTokenType(RefreshTokenType, claims.TokenType)
+	}
+
+	if err := checkTokenVersion(claims); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
+
+// checkTokenType 在签名校验失败后，不验证签名地读取令牌的 token_type 声明
+// 如果令牌是另一种类型（例如把访问令牌当作刷新令牌使用），返回明确的类型错误；否则返回 nil
+func checkTokenType(tokenString, expected string) error {
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return nil
+	}
+	if claims.TokenType != "" && claims.TokenType != expected {
+		return errWrongTokenType(expected, claims.TokenType)
+	}
+	return nil
+}
+
+// errWrongTokenType 构造令牌类型不匹配的错误
+func errWrongTokenType(expected, actual string) error {
+	if actual == "" {
+		actual = "unknown"
+	}
+	return fmt.Errorf("wrong token type: expected %s token, got %s token", expected, actual)
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTokenVersionLookup 在测试期间替换令牌版本查询函数和签名密钥
+func useTokenVersionLookup(t *testing.T, lookup TokenVersionLookup) {
+	t.Helper()
+	originalLookup, originalKey, originalRefreshKey := tokenVersionLookup, SECRET_KEY, SECRET_REFRESH_KEY
+	SetTokenVersionLookup(lookup)
+	SECRET_KEY, SECRET_REFRESH_KEY = "test-access-secret", "test-refresh-secret"
+	t.Cleanup(func() {
+		tokenVersionLookup, SECRET_KEY, SECRET_REFRESH_KEY = originalLookup, originalKey, originalRefreshKey
+	})
+}
+
+func TestValidateTokenChecksTokenVersion(t *testing.T) {
+	dbDown := errors.New("server selection timeout")
+	tests := []struct {
+		name    string
+		lookup  TokenVersionLookup
+		wantErr error
+	}{
+		{"current version", func(ctx context.Context, userId string) (int, error) { return 2, nil }, nil},
+		{"revoked by logout", func(ctx context.Context, userId string) (int, error) { return 3, nil }, ErrTokenRevoked},
+		{"user deleted", func(ctx context.Context, userId string) (int, error) { return 0, ErrTokenUserNotFound }, ErrTokenRevoked},
+		{"lookup failure", func(ctx context.Context, userId string) (int, error) { return 0, dbDown }, ErrTokenCheckUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTokenVersionLookup(t, tt.lookup)
+			token, refreshToken, err := GenerateAllTokens("a@example.com", "Alice", "Example", "USER", "u1", NewTokenID(), 2)
+			if err != nil {
+				t.Fatalf("GenerateAllTokens: %v", err)
+			}
+
+			if _, err := ValidateToken(token); !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("ValidateToken error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := ValidateRefreshToken(refreshToken); !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("ValidateRefreshToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupFailureIsNotReportedAsRevoked(t *testing.T) {
+	dbDown := errors.New("server selection timeout")
+	useTokenVersionLookup(t, func(ctx context.Context, userId string) (int, error) { return 0, dbDown })
+	token, _, err := GenerateAllTokens("a@example.com", "Alice", "Example", "USER", "u1", NewTokenID(), 0)
+	if err != nil {
+		t.Fatalf("GenerateAllTokens: %v", err)
+	}
+
+	_, err = ValidateToken(token)
+	if errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("error = %v, a lookup failure must not look like a revoked token", err)
+	}
+	if !errors.Is(err, dbDown) {
+		t.Errorf("error = %v, want it to wrap the lookup error", err)
+	}
+}
+
+func TestGetAccessTokenParsesBearerHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "missing header", header: "", wantErr: true},
+		{name: "other scheme", header: "Basic dXNlcjpwYXNz", wantErr: true},
+		{name: "short header", header: "Bear", wantErr: true},
+		{name: "empty bearer token", header: "Bearer   ", wantErr: true},
+		{name: "bearer token", header: "Bearer abc.def.ghi", want: "abc.def.ghi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			got, err := GetAccessToken(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetAccessToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetAccessToken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRequestAccessTokenPrefersCookie(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer header-token")
+	c.Request.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie-token"})
+	if got, err := GetRequestAccessToken(c); err != nil || got != "cookie-token" {
+		t.Errorf("GetRequestAccessToken = %q, %v; want cookie-token", got, err)
+	}
+
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer header-token")
+	if got, err := GetRequestAccessToken(c); err != nil || got != "header-token" {
+		t.Errorf("GetRequestAccessToken without cookie = %q, %v; want header-token", got, err)
+	}
+}